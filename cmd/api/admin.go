@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntry describes an action taken by the current user, for models that
+// record it in the same transaction as the action itself.
+func (app *application) auditEntry(c *gin.Context, action string, targetUserID int64, details map[string]interface{}) *data.AuditEntry {
+	entry := &data.AuditEntry{
+		ActorID: app.contextGetUser(c).ID,
+		Action:  action,
+		Details: details,
+	}
+	if targetUserID != 0 {
+		entry.TargetUserID = &targetUserID
+	}
+	return entry
+}
+
+func (app *application) listUsersHandler(c *gin.Context) {
+	var input struct {
+		Email      string
+		Permission string
+		data.Filters
+	}
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	input.Email = app.readString(qs, "email", "")
+	input.Permission = app.readString(qs, "permission", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	var users []*data.User
+	var metadata data.Metadata
+	var err error
+	if input.Permission != "" {
+		users, metadata, err = app.models.Permissions.GetUsersForPermission(input.Permission, input.Filters)
+	} else {
+		users, metadata, err = app.models.Users.GetAll(input.Email, input.Filters)
+	}
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showUserHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"user": user, "permissions": permissions, "roles": roles}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) grantUserPermissionsHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	v := validator.New()
+	v.Check(len(input.Permissions) > 0, "permissions", "must contain at least one permission")
+	v.Check(validator.Unique(input.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range input.Permissions {
+		v.Check(validator.In(code, known...), "permissions", "contains unknown permission "+code)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "permissions.grant", user.ID, map[string]interface{}{"permissions": input.Permissions})
+	if err := app.models.Permissions.GrantForUser(user.ID, input.Permissions, entry); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	app.showUserHandler(c)
+}
+
+func (app *application) revokeUserPermissionHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	code := c.Param("code")
+	entry := app.auditEntry(c, "permissions.revoke", user.ID, map[string]interface{}{"permissions": []string{code}})
+	if err := app.models.Permissions.RevokeForUser(user.ID, []string{code}, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrAdminLockout):
+			v := validator.New()
+			v.AddError("permission", "you cannot revoke your own admin permission")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	app.showUserHandler(c)
+}
+
+func (app *application) deactivateUserHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	if user.ID == app.contextGetUser(c).ID {
+		v := validator.New()
+		v.AddError("user", "you cannot deactivate your own account")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Users.Deactivate(user, app.auditEntry(c, "user.deactivate", user.ID, nil)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// impersonateUserHandler issues a short-lived token that authenticates as
+// another user. Admins can't be impersonated, since that would let an admin
+// pick up permissions they don't hold.
+func (app *application) impersonateUserHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	v := validator.New()
+	if user.ID == app.contextGetUser(c).ID {
+		v.AddError("user", "you cannot impersonate yourself")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if permissions.Include("users:admin") {
+		v.AddError("user", "you cannot impersonate another admin")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "user.impersonate", user.ID, nil)
+	token, err := app.models.Tokens.NewImpersonation(user.ID, app.contextGetUser(c).ID, app.config.tokens.impersonationTTL, entry)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"impersonation_token": token}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) listAuditHandler(c *gin.Context) {
+	var input struct {
+		UserID int
+		data.Filters
+	}
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	input.UserID = app.readInt(qs, "user_id", 0, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafeList = []string{"id", "created_at", "-id", "-created_at"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entries, metadata, err := app.models.Audit.GetAll(int64(input.UserID), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"audit_log": entries, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// readUserParam loads the user identified by the id route parameter, sending
+// the error response itself and returning false if it can't.
+func (app *application) readUserParam(c *gin.Context) (*data.User, bool) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listAPIKeysHandler(c *gin.Context) {
+	user := app.contextGetUser(c)
+	keys, err := app.models.APIKeys.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"api_keys": keys}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createAPIKeyHandler(c *gin.Context) {
+	user := app.contextGetUser(c)
+	var input struct {
+		Name        string     `json:"name"`
+		Permissions []string   `json:"permissions"`
+		Expiry      *time.Time `json:"expiry"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	owner, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	key, err := data.GenerateAPIKey(user.ID, input.Name, input.Permissions, input.Expiry)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateAPIKey(v, key, known, owner); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.APIKeys.Insert(key); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"api_key": key}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteAPIKeyHandler(c *gin.Context) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	user := app.contextGetUser(c)
+	if err := app.models.APIKeys.DeleteForUser(user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "API key successfully revoked"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
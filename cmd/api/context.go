@@ -1,13 +1,22 @@
 package main
 
 import (
+	"errors"
+
 	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/jwt"
 	"github.com/gin-gonic/gin"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey   = contextKey("user")
+	tokenContextKey  = contextKey("token")
+	claimsContextKey = contextKey("claims")
+	apiKeyContextKey = contextKey("api_key")
+	actorContextKey  = contextKey("actor")
+)
 
 func (app *application) contextSetUser(c *gin.Context, user *data.User) {
 	c.Set(string(userContextKey), user)
@@ -24,3 +33,71 @@ func (app *application) contextGetUser(c *gin.Context) *data.User {
 	}
 	return dataUser
 }
+
+func (app *application) contextSetToken(c *gin.Context, token string) {
+	c.Set(string(tokenContextKey), token)
+}
+
+func (app *application) contextGetToken(c *gin.Context) string {
+	return c.GetString(string(tokenContextKey))
+}
+
+func (app *application) contextSetClaims(c *gin.Context, claims *jwt.Claims) {
+	c.Set(string(claimsContextKey), claims)
+}
+
+// contextGetClaims returns the claims of the signed access token used for the
+// request, or nil when the request was authenticated with an opaque token.
+func (app *application) contextGetClaims(c *gin.Context) *jwt.Claims {
+	claims, ok := c.Get(string(claimsContextKey))
+	if !ok {
+		return nil
+	}
+	return claims.(*jwt.Claims)
+}
+
+func (app *application) contextSetAPIKey(c *gin.Context, key *data.APIKey) {
+	c.Set(string(apiKeyContextKey), key)
+}
+
+// contextGetAPIKey returns the API key used for the request, or nil when the
+// request was not authenticated with one.
+func (app *application) contextGetAPIKey(c *gin.Context) *data.APIKey {
+	key, ok := c.Get(string(apiKeyContextKey))
+	if !ok {
+		return nil
+	}
+	return key.(*data.APIKey)
+}
+
+func (app *application) contextSetActor(c *gin.Context, actorID int64) {
+	c.Set(string(actorContextKey), actorID)
+}
+
+// contextGetActor returns the ID of the admin impersonating the user, or 0
+// when the request isn't made with an impersonation token.
+func (app *application) contextGetActor(c *gin.Context) int64 {
+	return c.GetInt64(string(actorContextKey))
+}
+
+// currentUser returns the complete record of the authenticated user. Signed
+// access tokens only carry the user ID and activation state, so in that mode
+// the record is loaded from the database. If that fails, the error response
+// has been sent and false is returned.
+func (app *application) currentUser(c *gin.Context) (*data.User, bool) {
+	user := app.contextGetUser(c)
+	if app.contextGetClaims(c) == nil {
+		return user, true
+	}
+	user, err := app.models.Users.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
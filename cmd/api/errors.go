@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -53,11 +56,38 @@ func (app *application) rateLimitExceededResponse(c *gin.Context) {
 	app.errorResponse(c, http.StatusTooManyRequests, message)
 }
 
+func (app *application) tooManyLoginAttemptsResponse(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "too many failed login attempts, please try again later"
+	app.errorResponse(c, http.StatusTooManyRequests, message)
+}
+
+func (app *application) accountLockedResponse(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "this account is temporarily locked due to too many failed login attempts"
+	app.errorResponse(c, http.StatusLocked, message)
+}
+
 func (app *application) invalidCredentialsResponse(c *gin.Context) {
 	message := "invalid authentication credentials"
 	app.errorResponse(c, http.StatusUnauthorized, message)
 }
 
+func (app *application) invalidRefreshTokenResponse(c *gin.Context) {
+	message := "invalid or expired refresh token"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidOIDCLoginResponse(c *gin.Context) {
+	message := "invalid or expired single sign-on login"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
+func (app *application) unverifiedEmailResponse(c *gin.Context) {
+	message := "your identity provider has not verified your email address"
+	app.errorResponse(c, http.StatusForbidden, message)
+}
+
 func (app *application) invalidAuthenticationResponse(c *gin.Context) {
 	c.Header("WWW-Authenticate", "Bearer")
 	message := "invalid or missing authentication token"
@@ -69,6 +99,11 @@ func (app *application) authenticationRequiredResponse(c *gin.Context) {
 	app.errorResponse(c, http.StatusUnauthorized, message)
 }
 
+func (app *application) reauthenticationRequiredResponse(c *gin.Context) {
+	message := "you must sign in again to perform this action"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
 func (app *application) inactiveAccountResponse(c *gin.Context) {
 	message := "your user account must be activated to access this resource"
 	app.errorResponse(c, http.StatusForbidden, message)
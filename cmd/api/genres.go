@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listGenresHandler(c *gin.Context) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"genres": genres}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createGenreHandler(c *gin.Context) {
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	vocabulary, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	genre := &data.Genre{
+		Slug:    input.Slug,
+		Name:    input.Name,
+		Aliases: input.Aliases,
+	}
+	if genre.Slug == "" {
+		genre.Slug = data.Slugify(genre.Name)
+	}
+	v := validator.New()
+	if data.ValidateGenre(v, genre, vocabulary); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "genre.create", 0, map[string]interface{}{"genre": genre.Slug})
+	if err := app.models.Genres.Insert(genre, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/genres/%d", genre.ID))
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"genre": genre}, headers); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showGenreHandler(c *gin.Context) {
+	genre, ok := app.readGenreParam(c)
+	if !ok {
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"genre": genre}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// updateGenreHandler edits a genre. Changing the slug renames the genre in
+// every movie that uses it.
+func (app *application) updateGenreHandler(c *gin.Context) {
+	genre, ok := app.readGenreParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Slug    *string  `json:"slug"`
+		Name    *string  `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	oldSlug := genre.Slug
+	if input.Slug != nil {
+		genre.Slug = *input.Slug
+	}
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+	if input.Aliases != nil {
+		genre.Aliases = input.Aliases
+	}
+	vocabulary, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateGenre(v, genre, vocabulary); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "genre.update", 0, map[string]interface{}{"genre": genre.Slug, "previous_slug": oldSlug})
+	if err := app.models.Genres.Update(genre, oldSlug, app.revisionActor(c), entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(c, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"genre": genre}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteGenreHandler(c *gin.Context) {
+	genre, ok := app.readGenreParam(c)
+	if !ok {
+		return
+	}
+	entry := app.auditEntry(c, "genre.delete", 0, map[string]interface{}{"genre": genre.Slug})
+	if err := app.models.Genres.Delete(genre.ID, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		case errors.Is(err, data.ErrGenreInUse):
+			v := validator.New()
+			v.AddError("genre", "is still used by one or more movies")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// mergeGenreHandler folds a genre into another one, moving its movies and
+// keeping its spellings as aliases of the target.
+func (app *application) mergeGenreHandler(c *gin.Context) {
+	source, ok := app.readGenreParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Into string `json:"into"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	vocabulary, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	var target *data.Genre
+	slug, found := vocabulary.Resolve(input.Into)
+	for _, genre := range vocabulary {
+		if found && genre.Slug == slug {
+			target = genre
+		}
+	}
+	v := validator.New()
+	v.Check(input.Into != "", "into", "must be provided")
+	v.Check(input.Into == "" || target != nil, "into", "is not a known genre")
+	v.Check(target == nil || target.ID != source.ID, "into", "must be a different genre")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "genre.merge", 0, map[string]interface{}{"genre": target.Slug, "merged_slug": source.Slug})
+	if err := app.models.Genres.Merge(source, target, app.revisionActor(c), entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	target, err = app.models.Genres.Get(target.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"genre": target}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) readGenreParam(c *gin.Context) (*data.Genre, bool) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	genre, err := app.models.Genres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return genre, true
+}
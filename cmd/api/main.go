@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
@@ -13,8 +15,10 @@ import (
 	"time"
 
 	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/jwt"
 	"github.com/Sukrati192/greenlight/internal/logger"
 	"github.com/Sukrati192/greenlight/internal/mailer"
+	"github.com/Sukrati192/greenlight/internal/oidc"
 	_ "github.com/lib/pq"
 )
 
@@ -23,6 +27,10 @@ var (
 	buildTime string
 )
 
+// maxSignedTokenTTL caps the access token lifetime in signed token mode,
+// since a signed token can't be revoked before it expires.
+const maxSignedTokenTTL = time.Hour
+
 type config struct {
 	port int
 	env  string
@@ -47,6 +55,34 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	tokens struct {
+		mode             string
+		accessTTL        time.Duration
+		refreshTTL       time.Duration
+		signingKeys      map[string][]byte
+		signingKey       string
+		impersonationTTL time.Duration
+	}
+	permissionsCache struct {
+		ttl time.Duration
+	}
+	lockout struct {
+		emailThreshold int
+		ipThreshold    int
+		duration       time.Duration
+		window         time.Duration
+		pruneInterval  time.Duration
+	}
+	oidc struct {
+		issuer       string
+		clientID     string
+		clientSecret string
+		redirectURL  string
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
 }
 
 type application struct {
@@ -54,6 +90,8 @@ type application struct {
 	logger *logger.Logger
 	models data.Models
 	mailer mailer.Mailer
+	signer *jwt.Signer
+	oidc   *oidc.Provider
 	wg     sync.WaitGroup
 }
 
@@ -77,6 +115,41 @@ func main() {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
 	})
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 15*time.Minute, "Authentication token lifetime (at most 1h in signed mode, where it is the only bound on a token outliving a logout)")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Refresh token lifetime")
+	flag.StringVar(&cfg.tokens.mode, "token-mode", "opaque", "Authentication token mode (opaque|signed)")
+	flag.Func("token-signing-keys", "Token signing keys as space separated key-id:base64-secret pairs", func(val string) error {
+		cfg.tokens.signingKeys = make(map[string][]byte)
+		for _, pair := range strings.Fields(val) {
+			keyID, secret, found := strings.Cut(pair, ":")
+			if !found || keyID == "" {
+				return fmt.Errorf("invalid signing key %q", pair)
+			}
+			key, err := base64.StdEncoding.DecodeString(secret)
+			if err != nil {
+				return fmt.Errorf("invalid signing key %q: %w", keyID, err)
+			}
+			if len(key) < 32 {
+				return fmt.Errorf("signing key %q must be at least 32 bytes long", keyID)
+			}
+			cfg.tokens.signingKeys[keyID] = key
+		}
+		return nil
+	})
+	flag.StringVar(&cfg.tokens.signingKey, "token-signing-key-id", "", "ID of the key used to sign new tokens")
+	flag.DurationVar(&cfg.tokens.impersonationTTL, "impersonation-token-ttl", 30*time.Minute, "Admin impersonation token lifetime")
+	flag.DurationVar(&cfg.permissionsCache.ttl, "permissions-cache-ttl", 30*time.Second, "How long user permissions are cached (0 disables caching)")
+	flag.IntVar(&cfg.lockout.emailThreshold, "lockout-email-failures", 5, "Failed logins for an email address before it is locked out")
+	flag.IntVar(&cfg.lockout.ipThreshold, "lockout-ip-failures", 20, "Failed logins from an IP address before it is locked out")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", time.Minute, "Initial lockout duration, doubled on each further failure")
+	flag.DurationVar(&cfg.lockout.window, "lockout-window", 15*time.Minute, "Period without failures, after any lockout has ended, before failed login counters reset")
+	flag.DurationVar(&cfg.lockout.pruneInterval, "lockout-prune-interval", time.Hour, "How often stale failed login counters are deleted")
+	flag.StringVar(&cfg.oidc.issuer, "oidc-issuer", "", "OpenID Connect issuer URL (empty disables single sign-on)")
+	flag.StringVar(&cfg.oidc.clientID, "oidc-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&cfg.oidc.clientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	flag.StringVar(&cfg.oidc.redirectURL, "oidc-redirect-url", "", "URL the identity provider redirects back to after login")
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted movies are kept before being purged (0 disables purging)")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often deleted movies past the retention period are purged")
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -88,6 +161,41 @@ func main() {
 	}
 
 	logger := logger.New(os.Stdout, logger.LevelInfo)
+	var signer *jwt.Signer
+	switch cfg.tokens.mode {
+	case "opaque":
+	case "signed":
+		s, err := jwt.New(cfg.tokens.signingKeys, cfg.tokens.signingKey)
+		if err != nil {
+			logger.PrintFatal(err, map[string]string{"key_id": cfg.tokens.signingKey})
+		}
+		// Signed tokens are never looked up, so logging out, resetting the
+		// password or being deactivated can't revoke them.
+		if cfg.tokens.accessTTL > maxSignedTokenTTL {
+			logger.PrintFatal(fmt.Errorf("access-token-ttl must be at most %s in signed token mode", maxSignedTokenTTL), nil)
+		}
+		signer = s
+	default:
+		logger.PrintFatal(fmt.Errorf("invalid token mode %q", cfg.tokens.mode), nil)
+	}
+	var provider *oidc.Provider
+	if cfg.oidc.issuer != "" {
+		if cfg.oidc.clientID == "" || cfg.oidc.redirectURL == "" {
+			logger.PrintFatal(errors.New("oidc-client-id and oidc-redirect-url must be set with oidc-issuer"), nil)
+		}
+		provider = oidc.New(oidc.Config{
+			Issuer:       cfg.oidc.issuer,
+			ClientID:     cfg.oidc.clientID,
+			ClientSecret: cfg.oidc.clientSecret,
+			RedirectURL:  cfg.oidc.redirectURL,
+		}, nil)
+	}
+	if cfg.trash.retention > 0 && cfg.trash.purgeInterval <= 0 {
+		logger.PrintFatal(errors.New("trash-purge-interval must be positive when trash-retention is set"), nil)
+	}
+	if cfg.lockout.pruneInterval <= 0 {
+		logger.PrintFatal(errors.New("lockout-prune-interval must be positive"), nil)
+	}
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -104,11 +212,21 @@ func main() {
 	expvar.Publish("timestamp", expvar.Func(func() interface{} {
 		return time.Now().Unix()
 	}))
+	models := data.NewModels(db)
+	if cfg.permissionsCache.ttl > 0 {
+		cache := data.NewPermissionsCache(cfg.permissionsCache.ttl)
+		models = cache.Wrap(models)
+		expvar.Publish("permissions_cache", expvar.Func(func() interface{} {
+			return cache.Stats()
+		}))
+	}
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
+		models: models,
 		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		signer: signer,
+		oidc:   provider,
 	}
 	err = app.serve()
 	if err != nil {
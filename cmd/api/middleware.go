@@ -69,6 +69,32 @@ func (app *application) rateLimit() gin.HandlerFunc {
 }
 
 func (app *application) authenticate() gin.HandlerFunc {
+	// Last use times are buffered in memory and flushed in batches so that
+	// authenticated requests don't each cost a database write.
+	var (
+		mu              sync.Mutex
+		lastUsed        = make(map[string]time.Time)
+		apiKeysLastUsed = make(map[int64]time.Time)
+	)
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			mu.Lock()
+			pending, pendingAPIKeys := lastUsed, apiKeysLastUsed
+			lastUsed, apiKeysLastUsed = make(map[string]time.Time), make(map[int64]time.Time)
+			mu.Unlock()
+			if len(pending) != 0 {
+				if err := app.models.Tokens.UpdateLastUsed(pending); err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			}
+			if len(pendingAPIKeys) != 0 {
+				if err := app.models.APIKeys.UpdateLastUsed(pendingAPIKeys); err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			}
+		}
+	}()
 	return func(c *gin.Context) {
 		c.Writer.Header().Add("Vary", "Authorization")
 		authorizationHeader := c.GetHeader("Authorization")
@@ -80,14 +106,65 @@ func (app *application) authenticate() gin.HandlerFunc {
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			app.invalidAuthenticationResponse(c)
 			c.Abort()
+			return
 		}
 		token := headerParts[1]
+		if strings.HasPrefix(token, data.APIKeyPrefix) {
+			v := validator.New()
+			if data.ValidateAPIKeyPlaintext(v, token); !v.Valid() {
+				app.invalidAuthenticationResponse(c)
+				c.Abort()
+				return
+			}
+			user, key, err := app.models.APIKeys.GetForKey(token)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					app.invalidAuthenticationResponse(c)
+				default:
+					app.serverErrorResponse(c, err)
+				}
+				c.Abort()
+				return
+			}
+			mu.Lock()
+			apiKeysLastUsed[key.ID] = time.Now()
+			mu.Unlock()
+			app.contextSetUser(c, user)
+			app.contextSetAPIKey(c, key)
+			return
+		}
+		// Signed tokens are checked without touching the database, so one stays
+		// valid until it expires even after its session is logged out, its
+		// user's password is reset or its user is deactivated. The short
+		// access token lifetime, capped at maxSignedTokenTTL, is the only
+		// bound on that.
+		if app.signer != nil && strings.Count(token, ".") == 2 {
+			claims, err := app.signer.Verify(token, time.Now())
+			if err != nil {
+				app.invalidAuthenticationResponse(c)
+				c.Abort()
+				return
+			}
+			mu.Lock()
+			lastUsed[string(data.HashToken(token))] = time.Now()
+			mu.Unlock()
+			app.contextSetUser(c, &data.User{ID: claims.Subject, Activated: claims.Activated})
+			app.contextSetClaims(c, claims)
+			app.contextSetToken(c, token)
+			return
+		}
 		v := validator.New()
 		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
 			app.invalidAuthenticationResponse(c)
 			c.Abort()
+			return
 		}
 		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		var actorID int64
+		if errors.Is(err, data.ErrRecordNotFound) {
+			user, actorID, err = app.models.Users.GetForImpersonationToken(token)
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -96,8 +173,38 @@ func (app *application) authenticate() gin.HandlerFunc {
 				app.serverErrorResponse(c, err)
 			}
 			c.Abort()
+			return
 		}
 		app.contextSetUser(c, user)
+		app.contextSetToken(c, token)
+		if actorID == 0 {
+			mu.Lock()
+			lastUsed[string(data.HashToken(token))] = time.Now()
+			mu.Unlock()
+			return
+		}
+		// An impersonation token stops working as soon as the admin who
+		// created it loses the permission to impersonate.
+		permissions, err := app.models.Permissions.GetAllForUser(actorID)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+		if !permissions.Include("users:admin") {
+			app.invalidAuthenticationResponse(c)
+			c.Abort()
+			return
+		}
+		app.contextSetActor(c, actorID)
+		c.Next()
+		app.logger.PrintInfo("impersonated request", map[string]string{
+			"actor_id": strconv.FormatInt(actorID, 10),
+			"user_id":  strconv.FormatInt(user.ID, 10),
+			"method":   c.Request.Method,
+			"path":     c.Request.URL.Path,
+			"status":   strconv.Itoa(c.Writer.Status()),
+		})
 	}
 }
 
@@ -131,14 +238,58 @@ func (app *application) requirePermission(code string) gin.HandlerFunc {
 		if c.Writer.Written() {
 			return
 		}
-		user := app.contextGetUser(c)
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		ok, err := app.hasPermission(c, code)
 		if err != nil {
 			app.serverErrorResponse(c, err)
 			c.Abort()
 			return
 		}
-		if !permissions.Include(code) {
+		if !ok {
+			app.notPermittedResponse(c)
+			c.Abort()
+		}
+	}
+}
+
+// hasPermission reports whether the authenticated user holds the permission,
+// limited to the permissions of the API key if the request used one.
+func (app *application) hasPermission(c *gin.Context, code string) (bool, error) {
+	var permissions data.Permissions
+	if claims := app.contextGetClaims(c); claims != nil {
+		permissions = claims.Permissions
+	} else {
+		var err error
+		user := app.contextGetUser(c)
+		permissions, err = app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !permissions.Include(code) {
+		return false, nil
+	}
+	if key := app.contextGetAPIKey(c); key != nil && !key.Permissions.Include(code) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// rejectImpersonation blocks routes that manage credentials, so an admin
+// acting as a user can't take over their account.
+func (app *application) rejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.contextGetActor(c) != 0 {
+			app.notPermittedResponse(c)
+			c.Abort()
+		}
+	}
+}
+
+// rejectAPIKey stops API keys from being used to manage credentials, which
+// should always require a user's own session.
+func (app *application) rejectAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.contextGetAPIKey(c) != nil {
 			app.notPermittedResponse(c)
 			c.Abort()
 		}
@@ -30,12 +30,17 @@ func (app *application) createMoviesHandler(c *gin.Context) {
 		Runtime: input.Runtime,
 		Genres:  input.Genres,
 	}
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
-	if err := app.models.Movies.Insert(movie); err != nil {
+	if err := app.models.Movies.Insert(movie, app.revisionActor(c)); err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
@@ -84,11 +89,8 @@ func (app *application) updateMoviesHandler(c *gin.Context) {
 		}
 		return
 	}
-	if expectedVersion := c.Request.Header.Get("X-Expected-Version"); expectedVersion != "" {
-		if strconv.FormatInt(int64(movie.Version), 32) != expectedVersion {
-			app.editConflictResponse(c)
-			return
-		}
+	if !app.checkExpectedVersion(c, movie) {
+		return
 	}
 	var input struct {
 		Title   *string       `json:"title,omitempty"`
@@ -112,12 +114,17 @@ func (app *application) updateMoviesHandler(c *gin.Context) {
 	if input.Genres != nil {
 		movie.Genres = input.Genres
 	}
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
-	if err := app.models.Movies.Update(movie); err != nil {
+	if err := app.models.Movies.Update(movie, data.RevisionUpdate, app.revisionActor(c)); err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(c)
@@ -132,12 +139,54 @@ func (app *application) updateMoviesHandler(c *gin.Context) {
 }
 
 func (app *application) deleteMoviesHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	if err := app.models.Movies.Delete(movie, app.revisionActor(c)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+}
+
+func (app *application) listTrashHandler(c *gin.Context) {
+	var filters data.Filters
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	filters.SortSafeList = []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	movies, metadata, err := app.models.Movies.GetDeleted(filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) restoreMovieHandler(c *gin.Context) {
 	id, err := app.readIDParam(c)
 	if err != nil {
 		app.badRequestResponse(c, err)
 		return
 	}
-	if err := app.models.Movies.Delete(id); err != nil {
+	if err := app.models.Movies.Restore(id, app.revisionActor(c)); err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(c)
@@ -146,7 +195,11 @@ func (app *application) deleteMoviesHandler(c *gin.Context) {
 		}
 		return
 	}
-	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil); err != nil {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"movie": movie}, nil); err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
@@ -154,26 +207,65 @@ func (app *application) deleteMoviesHandler(c *gin.Context) {
 
 func (app *application) listMoviesHandler(c *gin.Context) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieSearch
 		data.Filters
 	}
 	v := validator.New()
 	qs := c.Request.URL.Query()
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Director = app.readString(qs, "director", "")
+	input.Actor = app.readString(qs, "actor", "")
+	input.YearMin = app.readInt(qs, "year_min", 0, v)
+	input.YearMax = app.readInt(qs, "year_max", 0, v)
+	input.RuntimeMin = app.readInt(qs, "runtime_min", 0, v)
+	input.RuntimeMax = app.readInt(qs, "runtime_max", 0, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	// Relevance is only useful most relevant first.
+	if input.Filters.Sort == "relevance" {
+		input.Filters.Sort = "-relevance"
+	}
+	if input.Filters.Sort == "-relevance" {
+		v.Check(input.Title != "", "sort", "relevance requires a title search")
+	}
+	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "rating", "-id", "-title", "-year", "-runtime", "-rating", "-relevance"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.SkipTotal = !app.readBool(qs, "include_total", true, v)
+	data.ValidateMovieSearch(v, input.MovieSearch)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	if len(input.Genres) != 0 {
+		genres, err := app.models.Genres.GetAll()
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		for i, genre := range input.Genres {
+			if slug, ok := genres.Resolve(genre); ok {
+				input.Genres[i] = slug
+			}
+		}
+	}
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
 	app.writeJSON(c, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
 }
+
+// checkExpectedVersion enforces the optional X-Expected-Version header, sending
+// an edit conflict response and returning false if it doesn't match.
+func (app *application) checkExpectedVersion(c *gin.Context, movie *data.Movie) bool {
+	if expectedVersion := c.Request.Header.Get("X-Expected-Version"); expectedVersion != "" {
+		if strconv.FormatInt(int64(movie.Version), 32) != expectedVersion {
+			app.editConflictResponse(c)
+			return false
+		}
+	}
+	return true
+}
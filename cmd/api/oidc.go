@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/oidc"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// startOIDCLoginHandler begins an authorization code login. The client sends
+// the user to the returned URL and, once the identity provider redirects back
+// to it, posts the code and state to createOIDCAuthenticationHandler.
+func (app *application) startOIDCLoginHandler(c *gin.Context) {
+	var values [3]string
+	for i := range values {
+		value, err := oidc.NewVerifier()
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	authURL, err := app.oidc.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.Identities.InsertLogin(state, verifier, nonce, 10*time.Minute); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"authorization_url": authURL, "state": state}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createOIDCAuthenticationHandler(c *gin.Context) {
+	var input struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+	v.Check(input.State != "", "state", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	verifier, nonce, err := app.models.Identities.ConsumeLogin(input.State)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidOIDCLoginResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	idToken, err := app.oidc.Exchange(c.Request.Context(), input.Code, verifier, nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrNonceMismatch):
+			app.logger.PrintInfo("oidc login rejected", map[string]string{"error": err.Error()})
+			app.invalidOIDCLoginResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	user, err := app.models.Identities.GetUser(idToken.Issuer, idToken.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrRecordNotFound):
+		if !idToken.EmailVerified {
+			app.unverifiedEmailResponse(c)
+			return
+		}
+		user, err = app.linkOIDCIdentity(idToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrDuplicateEmail), errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(c)
+			default:
+				app.serverErrorResponse(c, err)
+			}
+			return
+		}
+	default:
+		app.serverErrorResponse(c, err)
+		return
+	}
+	app.completeLogin(c, user)
+}
+
+// linkOIDCIdentity attaches an external identity to the user with the same
+// email address, creating an activated user if there isn't one. The caller
+// must have checked that the provider verified the email address, which also
+// proves ownership for an existing user who never activated their account;
+// such a user is activated with a new random password.
+func (app *application) linkOIDCIdentity(idToken *oidc.IDToken) (*data.User, error) {
+	user, err := app.models.Users.GetByEmail(idToken.Email)
+	switch {
+	case err == nil:
+		if !user.Activated {
+			// Anyone could have registered this address, so the password they
+			// chose must not keep working once the owner signs in.
+			password, err := oidc.NewVerifier()
+			if err != nil {
+				return nil, err
+			}
+			if err := user.Password.Set(password); err != nil {
+				return nil, err
+			}
+			if err := app.models.Users.Claim(user); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, data.ErrRecordNotFound):
+		user = &data.User{
+			Name:      idToken.Name,
+			Email:     idToken.Email,
+			Activated: true,
+		}
+		if user.Name == "" {
+			user.Name, _, _ = strings.Cut(idToken.Email, "@")
+		}
+		// The user never learns this password; they can set one later through
+		// the password reset flow if they want to stop using SSO.
+		password, err := oidc.NewVerifier()
+		if err != nil {
+			return nil, err
+		}
+		if err := user.Password.Set(password); err != nil {
+			return nil, err
+		}
+		v := validator.New()
+		if data.ValidateUser(v, user); !v.Valid() {
+			return nil, errors.New("identity provider returned an invalid user")
+		}
+		if err := app.models.Users.Insert(user); err != nil {
+			return nil, err
+		}
+		if err := app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	identity := &data.Identity{Issuer: idToken.Issuer, Subject: idToken.Subject, UserID: user.ID}
+	if err := app.models.Identities.Insert(identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
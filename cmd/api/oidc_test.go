@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/oidc"
+	"github.com/Sukrati192/greenlight/internal/oidc/oidctest"
+)
+
+// stubUsers keeps users in memory. Methods the tests don't use panic through
+// the nil embedded interface.
+type stubUsers struct {
+	data.UsersInterface
+	users map[string]data.User
+}
+
+func (m stubUsers) GetByEmail(email string) (*data.User, error) {
+	user, ok := m.users[email]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (m stubUsers) Claim(user *data.User) error {
+	user.Activated = true
+	user.Version++
+	m.users[user.Email] = *user
+	return nil
+}
+
+type stubIdentities struct {
+	data.IdentitiesInterface
+}
+
+func (m stubIdentities) Insert(identity *data.Identity) error {
+	return nil
+}
+
+func TestLinkOIDCIdentityResetsPassword(t *testing.T) {
+	idp := oidctest.NewProvider(t)
+	// Someone else registered the address and never activated the account.
+	squatter := data.User{ID: 1, Name: "Mallory", Email: "alice@example.com", Version: 1}
+	if err := squatter.Password.Set("pa55word"); err != nil {
+		t.Fatal(err)
+	}
+	users := stubUsers{users: map[string]data.User{squatter.Email: squatter}}
+	app := &application{
+		models: data.Models{Users: users, Identities: stubIdentities{}},
+		oidc:   oidc.New(idp.Config(), nil),
+	}
+	idToken, err := idp.Login(t, app.oidc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.linkOIDCIdentity(idToken); err != nil {
+		t.Fatalf("linkOIDCIdentity() error = %v", err)
+	}
+	user := users.users[squatter.Email]
+	if !user.Activated {
+		t.Error("linkOIDCIdentity() left the user unactivated")
+	}
+	if match, err := user.Password.Matches("pa55word"); err != nil || match {
+		t.Errorf("old password still matches after linkOIDCIdentity(): %v, %v", match, err)
+	}
+}
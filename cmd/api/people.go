@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listPeopleHandler(c *gin.Context) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "name", "birth_year", "-id", "-name", "-birth_year"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	people, metadata, err := app.models.People.GetAll(input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"people": people, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showPersonHandler(c *gin.Context) {
+	person, ok := app.readPersonParam(c)
+	if !ok {
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"person": person}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createPersonHandler(c *gin.Context) {
+	var input struct {
+		Name        string            `json:"name"`
+		BirthYear   int32             `json:"birth_year"`
+		ExternalIDs map[string]string `json:"external_ids"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	person := &data.Person{
+		Name:        input.Name,
+		BirthYear:   input.BirthYear,
+		ExternalIDs: input.ExternalIDs,
+	}
+	v := validator.New()
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.People.Insert(person); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"person": person}, headers); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) updatePersonHandler(c *gin.Context) {
+	person, ok := app.readPersonParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name        *string           `json:"name"`
+		BirthYear   *int32            `json:"birth_year"`
+		ExternalIDs map[string]string `json:"external_ids"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if input.Name != nil {
+		person.Name = *input.Name
+	}
+	if input.BirthYear != nil {
+		person.BirthYear = *input.BirthYear
+	}
+	if input.ExternalIDs != nil {
+		person.ExternalIDs = input.ExternalIDs
+	}
+	v := validator.New()
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.People.Update(person); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"person": person}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deletePersonHandler(c *gin.Context) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if err := app.models.People.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "person successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) listPersonMoviesHandler(c *gin.Context) {
+	person, ok := app.readPersonParam(c)
+	if !ok {
+		return
+	}
+	credits, err := app.models.People.GetCreditsForPerson(person.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"person": person, "credits": credits}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) listMovieCreditsHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	credits, err := app.models.People.GetCreditsForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"credits": credits}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createMovieCreditHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		PersonID  int64  `json:"person_id"`
+		Role      string `json:"role"`
+		Character string `json:"character"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	credit := &data.Credit{
+		MovieID:   movie.ID,
+		PersonID:  input.PersonID,
+		Role:      input.Role,
+		Character: input.Character,
+	}
+	v := validator.New()
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if _, err := app.models.People.Get(credit.PersonID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("person_id", "must refer to an existing person")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.People.InsertCredit(credit); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "already has this credit on the movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"credit": credit}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteMovieCreditHandler(c *gin.Context) {
+	movieID, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("credit_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.badRequestResponse(c, errors.New("invalid credit_id parameter"))
+		return
+	}
+	if err := app.models.People.DeleteCredit(movieID, id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "credit successfully removed"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) readPersonParam(c *gin.Context) (*data.Person, bool) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return person, true
+}
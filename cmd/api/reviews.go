@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listReviewsHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	var filters data.Filters
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-created_at")
+	filters.SortSafeList = []string{"id", "rating", "created_at", "-id", "-rating", "-created_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(movie.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showReviewHandler(c *gin.Context) {
+	review, ok := app.readReviewParam(c)
+	if !ok {
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"review": review}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createReviewHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Rating int32  `json:"rating"`
+		Body   string `json:"body"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	review := &data.Review{
+		MovieID: movie.ID,
+		UserID:  app.contextGetUser(c).ID,
+		Rating:  input.Rating,
+		Body:    input.Body,
+	}
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Reviews.Insert(review); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("movie", "you have already reviewed this movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/reviews/%d", movie.ID, review.ID))
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"review": review}, headers); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) updateReviewHandler(c *gin.Context) {
+	review, ok := app.readReviewParam(c)
+	if !ok {
+		return
+	}
+	if !app.authorizeReviewChange(c, review) {
+		return
+	}
+	var input struct {
+		Rating *int32  `json:"rating"`
+		Body   *string `json:"body"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Reviews.Update(review); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"review": review}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteReviewHandler(c *gin.Context) {
+	review, ok := app.readReviewParam(c)
+	if !ok {
+		return
+	}
+	if !app.authorizeReviewChange(c, review) {
+		return
+	}
+	if err := app.models.Reviews.Delete(review.ID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "review successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// authorizeReviewChange lets authors with reviews:write change their own
+// reviews and holders of reviews:moderate change anyone's. It sends the error
+// response itself and returns false if the change isn't allowed.
+func (app *application) authorizeReviewChange(c *gin.Context, review *data.Review) bool {
+	code := "reviews:moderate"
+	if review.UserID == app.contextGetUser(c).ID {
+		code = "reviews:write"
+	}
+	ok, err := app.hasPermission(c, code)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return false
+	}
+	if !ok {
+		app.notPermittedResponse(c)
+		return false
+	}
+	return true
+}
+
+func (app *application) readMovieParam(c *gin.Context) (*data.Movie, bool) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return movie, true
+}
+
+// readReviewParam loads the review through its movie, so that reviews of
+// deleted movies can't be read or changed.
+func (app *application) readReviewParam(c *gin.Context) (*data.Review, bool) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("review_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.badRequestResponse(c, errors.New("invalid review_id parameter"))
+		return nil, false
+	}
+	review, err := app.models.Reviews.Get(movie.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return review, true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// revisionActor returns who a movie change should be attributed to: the
+// admin when they are impersonating a user, otherwise the user.
+func (app *application) revisionActor(c *gin.Context) int64 {
+	if actorID := app.contextGetActor(c); actorID != 0 {
+		return actorID
+	}
+	return app.contextGetUser(c).ID
+}
+
+// listRevisionsHandler works for deleted movies too, so their history can
+// still be inspected.
+func (app *application) listRevisionsHandler(c *gin.Context) {
+	movieID, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	var filters data.Filters
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-id")
+	filters.SortSafeList = []string{"id", "-id"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	revisions, metadata, err := app.models.Revisions.GetAllForMovie(movieID, filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// showRevisionHandler returns a revision with the fields that changed since
+// the revision given by ?compare=, or since the previous revision by default.
+func (app *application) showRevisionHandler(c *gin.Context) {
+	revision, ok := app.readRevisionParam(c)
+	if !ok {
+		return
+	}
+	var compare *data.Revision
+	var err error
+	explicit := c.Query("compare") != ""
+	if explicit {
+		id, parseErr := strconv.ParseInt(c.Query("compare"), 10, 64)
+		if parseErr != nil || id < 1 {
+			v := validator.New()
+			v.AddError("compare", "must be a revision ID")
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		compare, err = app.models.Revisions.Get(revision.MovieID, id)
+	} else {
+		compare, err = app.models.Revisions.GetPrevious(revision.MovieID, revision.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound) && explicit:
+			app.notFoundResponse(c)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	var from data.MovieSnapshot
+	var comparedTo *int64
+	if compare != nil {
+		from, comparedTo = compare.Snapshot, &compare.ID
+	}
+	changes := data.Diff(from, revision.Snapshot)
+	if err := app.writeJSON(c, http.StatusOK, envelope{"revision": revision, "compared_to": comparedTo, "changes": changes}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// restoreRevisionHandler copies a revision's fields onto the movie and saves
+// it like any other update, so it is subject to the same validation and
+// optimistic locking.
+func (app *application) restoreRevisionHandler(c *gin.Context) {
+	movie, ok := app.readMovieParam(c)
+	if !ok {
+		return
+	}
+	revision, ok := app.readRevisionParam(c)
+	if !ok {
+		return
+	}
+	if !app.checkExpectedVersion(c, movie) {
+		return
+	}
+	movie.Title = revision.Snapshot.Title
+	movie.Year = revision.Snapshot.Year
+	movie.Runtime = revision.Snapshot.Runtime
+	movie.Genres = revision.Snapshot.Genres
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Movies.Update(movie, data.RevisionRestore, app.revisionActor(c)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"movie": movie, "restored_from": revision.ID}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) readRevisionParam(c *gin.Context) (*data.Revision, bool) {
+	movieID, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("rev"), 10, 64)
+	if err != nil || id < 1 {
+		app.badRequestResponse(c, errors.New("invalid rev parameter"))
+		return nil, false
+	}
+	revision, err := app.models.Revisions.Get(movieID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return revision, true
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listRolesHandler(c *gin.Context) {
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"roles": roles}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createRoleHandler(c *gin.Context) {
+	var input struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	role := &data.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+	}
+	v := validator.New()
+	if data.ValidateRole(v, role, known); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "role.create", 0, map[string]interface{}{"role": role.Name, "permissions": role.Permissions})
+	if err := app.models.Roles.Insert(role, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/roles/%d", role.ID))
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"role": role}, headers); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showRoleHandler(c *gin.Context) {
+	role, ok := app.readRoleParam(c)
+	if !ok {
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"role": role}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) updateRoleHandler(c *gin.Context) {
+	role, ok := app.readRoleParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name        *string  `json:"name"`
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if input.Name != nil {
+		role.Name = *input.Name
+	}
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+	if input.Permissions != nil {
+		role.Permissions = input.Permissions
+	}
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateRole(v, role, known); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "role.update", 0, map[string]interface{}{"role": role.Name, "permissions": role.Permissions})
+	if err := app.models.Roles.Update(role, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(c, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		case errors.Is(err, data.ErrAdminLockout):
+			v.AddError("permissions", "you cannot remove admin permission from a role that gives it to you")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"role": role}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteRoleHandler(c *gin.Context) {
+	role, ok := app.readRoleParam(c)
+	if !ok {
+		return
+	}
+	entry := app.auditEntry(c, "role.delete", 0, map[string]interface{}{"role": role.Name})
+	if err := app.models.Roles.Delete(role.ID, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		case errors.Is(err, data.ErrAdminLockout):
+			v := validator.New()
+			v.AddError("role", "you cannot delete the role that gives you admin permission")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "role successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) assignUserRolesHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	known := make([]string, len(roles))
+	for i, role := range roles {
+		known[i] = role.Name
+	}
+	v := validator.New()
+	v.Check(len(input.Roles) > 0, "roles", "must contain at least one role")
+	v.Check(validator.Unique(input.Roles), "roles", "must not contain duplicate values")
+	for _, name := range input.Roles {
+		v.Check(validator.In(name, known...), "roles", "contains unknown role "+name)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	entry := app.auditEntry(c, "roles.assign", user.ID, map[string]interface{}{"roles": input.Roles})
+	if err := app.models.Roles.AssignForUser(user.ID, input.Roles, entry); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	app.showUserHandler(c)
+}
+
+func (app *application) unassignUserRoleHandler(c *gin.Context) {
+	user, ok := app.readUserParam(c)
+	if !ok {
+		return
+	}
+	name := c.Param("role")
+	entry := app.auditEntry(c, "roles.unassign", user.ID, map[string]interface{}{"roles": []string{name}})
+	if err := app.models.Roles.UnassignForUser(user.ID, []string{name}, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrAdminLockout):
+			v := validator.New()
+			v.AddError("role", "you cannot remove the role that gives you admin permission")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	app.showUserHandler(c)
+}
+
+func (app *application) readRoleParam(c *gin.Context) (*data.Role, bool) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return nil, false
+	}
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return role, true
+}
@@ -18,18 +18,105 @@ func (app *application) routes() *gin.Engine {
 	router.GET("/v1/healthcheck", app.healthcheckHandler)
 	router.POST("/v1/users", app.registerUserHandler)
 	router.PUT("/v1/users/activated", app.activateUserHandler)
+	router.PUT("/v1/users/password", app.updateUserPasswordHandler)
+	router.PUT("/v1/users/email", app.confirmEmailChangeHandler)
 	router.POST("/v1/tokens/authentication", app.createAuthentication)
+	router.POST("/v1/tokens/authentication/mfa", app.createMFAAuthentication)
+	router.DELETE("/v1/tokens/authentication", app.requireAuthenticatedUser(), app.deleteAuthenticationTokenHandler)
+	router.DELETE("/v1/tokens/authentication/all", app.requireAuthenticatedUser(), app.rejectAPIKey(), app.rejectImpersonation(), app.deleteAllAuthenticationTokensHandler)
+	router.POST("/v1/tokens/refresh", app.refreshAuthenticationHandler)
+	router.POST("/v1/tokens/activation", app.createActivationTokenHandler())
+	router.POST("/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
 	router.GET("/debug/vars", expvar.Handler())
+	if app.oidc != nil {
+		router.GET("/v1/tokens/oidc", app.startOIDCLoginHandler)
+		router.POST("/v1/tokens/oidc", app.createOIDCAuthenticationHandler)
+	}
+
+	currentUser := router.Group("/v1/users/me")
+	currentUser.Use(app.requireActivatedUser())
+	currentUser.GET("", app.showCurrentUserHandler)
+	currentUser.PATCH("", app.rejectAPIKey(), app.rejectImpersonation(), app.updateCurrentUserHandler)
+	currentUser.DELETE("", app.rejectAPIKey(), app.rejectImpersonation(), app.deleteCurrentUserHandler)
+	currentUser.GET("/export", app.rejectAPIKey(), app.rejectImpersonation(), app.exportCurrentUserHandler)
+	currentUser.POST("/email", app.rejectAPIKey(), app.rejectImpersonation(), app.requestEmailChangeHandler)
+	currentUser.GET("/sessions", app.listSessionsHandler)
+	currentUser.DELETE("/sessions/:id", app.rejectAPIKey(), app.rejectImpersonation(), app.deleteSessionHandler)
+	currentUser.POST("/totp", app.rejectAPIKey(), app.rejectImpersonation(), app.enrollTOTPHandler)
+	currentUser.POST("/totp/verify", app.rejectAPIKey(), app.rejectImpersonation(), app.verifyTOTPHandler)
+	currentUser.DELETE("/totp", app.rejectAPIKey(), app.rejectImpersonation(), app.disableTOTPHandler)
+	currentUser.GET("/api-keys", app.listAPIKeysHandler)
+	currentUser.POST("/api-keys", app.rejectAPIKey(), app.rejectImpersonation(), app.createAPIKeyHandler)
+	currentUser.DELETE("/api-keys/:id", app.rejectAPIKey(), app.rejectImpersonation(), app.deleteAPIKeyHandler)
+	currentUser.GET("/watchlist", app.requirePermission("movies:read"), app.listWatchlistHandler)
+	currentUser.POST("/watchlist", app.requirePermission("movies:read"), app.rejectAPIKey(), app.rejectImpersonation(), app.addWatchlistItemHandler)
+	currentUser.PATCH("/watchlist/:id", app.requirePermission("movies:read"), app.rejectAPIKey(), app.rejectImpersonation(), app.updateWatchlistItemHandler)
+	currentUser.DELETE("/watchlist/:id", app.requirePermission("movies:read"), app.rejectAPIKey(), app.rejectImpersonation(), app.deleteWatchlistItemHandler)
 
 	readMovies := router.Group("/v1/movies")
 	readMovies.Use(app.requirePermission("movies:read"))
 	readMovies.GET("", app.listMoviesHandler)
 	readMovies.GET("/:id", app.showMoviesHandler)
+	readMovies.GET("/:id/reviews", app.listReviewsHandler)
+	readMovies.GET("/:id/reviews/:review_id", app.showReviewHandler)
+	readMovies.GET("/:id/credits", app.listMovieCreditsHandler)
 
 	writeMovies := router.Group("/v1/movies")
 	writeMovies.Use(app.requirePermission("movies:write"))
 	writeMovies.POST("", app.createMoviesHandler)
 	writeMovies.PATCH("/:id", app.updateMoviesHandler)
 	writeMovies.DELETE("/:id", app.deleteMoviesHandler)
+	writeMovies.POST("/:id/credits", app.createMovieCreditHandler)
+	writeMovies.DELETE("/:id/credits/:credit_id", app.deleteMovieCreditHandler)
+	writeMovies.GET("/:id/revisions", app.listRevisionsHandler)
+	writeMovies.GET("/:id/revisions/:rev", app.showRevisionHandler)
+	writeMovies.POST("/:id/revisions/:rev/restore", app.restoreRevisionHandler)
+
+	adminMovies := router.Group("/v1/movies")
+	adminMovies.Use(app.requirePermission("movies:admin"))
+	adminMovies.GET("/trash", app.listTrashHandler)
+	adminMovies.POST("/:id/restore", app.restoreMovieHandler)
+
+	reviews := router.Group("/v1/movies/:id/reviews")
+	reviews.POST("", app.requirePermission("reviews:write"), app.createReviewHandler)
+	reviews.PATCH("/:review_id", app.requireActivatedUser(), app.updateReviewHandler)
+	reviews.DELETE("/:review_id", app.requireActivatedUser(), app.deleteReviewHandler)
+
+	router.GET("/v1/genres", app.requirePermission("movies:read"), app.listGenresHandler)
+
+	readPeople := router.Group("/v1/people")
+	readPeople.Use(app.requirePermission("movies:read"))
+	readPeople.GET("", app.listPeopleHandler)
+	readPeople.GET("/:id", app.showPersonHandler)
+	readPeople.GET("/:id/movies", app.listPersonMoviesHandler)
+
+	writePeople := router.Group("/v1/people")
+	writePeople.Use(app.requirePermission("movies:write"))
+	writePeople.POST("", app.createPersonHandler)
+	writePeople.PATCH("/:id", app.updatePersonHandler)
+	writePeople.DELETE("/:id", app.deletePersonHandler)
+
+	admin := router.Group("/v1/admin")
+	admin.Use(app.requirePermission("users:admin"))
+	admin.GET("/users", app.listUsersHandler)
+	admin.GET("/users/:id", app.showUserHandler)
+	admin.POST("/users/:id/permissions", app.grantUserPermissionsHandler)
+	admin.DELETE("/users/:id/permissions/:code", app.revokeUserPermissionHandler)
+	admin.POST("/users/:id/deactivate", app.deactivateUserHandler)
+	admin.POST("/users/:id/impersonate", app.rejectAPIKey(), app.impersonateUserHandler)
+	admin.POST("/users/:id/roles", app.assignUserRolesHandler)
+	admin.DELETE("/users/:id/roles/:role", app.unassignUserRoleHandler)
+	admin.GET("/roles", app.listRolesHandler)
+	admin.POST("/roles", app.createRoleHandler)
+	admin.GET("/roles/:id", app.showRoleHandler)
+	admin.PATCH("/roles/:id", app.updateRoleHandler)
+	admin.DELETE("/roles/:id", app.deleteRoleHandler)
+	admin.GET("/genres", app.listGenresHandler)
+	admin.POST("/genres", app.createGenreHandler)
+	admin.GET("/genres/:id", app.showGenreHandler)
+	admin.PATCH("/genres/:id", app.updateGenreHandler)
+	admin.DELETE("/genres/:id", app.deleteGenreHandler)
+	admin.POST("/genres/:id/merge", app.mergeGenreHandler)
+	admin.GET("/audit", app.listAuditHandler)
 	return router
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -22,6 +23,11 @@ func (app *application) serve() error {
 		ErrorLog:     log.New(app.logger, "", 0),
 	}
 	shutdownErr := make(chan error)
+	stopPurge := make(chan struct{})
+	if app.config.trash.retention > 0 {
+		app.background(func() { app.purgeTrash(stopPurge) })
+	}
+	app.background(func() { app.pruneLoginAttempts(stopPurge) })
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -32,6 +38,7 @@ func (app *application) serve() error {
 		if err := srv.Shutdown(ctx); err != nil {
 			shutdownErr <- err
 		}
+		close(stopPurge)
 		app.logger.PrintInfo("completing background tasks", map[string]string{"addr": srv.Addr})
 		app.wg.Wait()
 		shutdownErr <- nil
@@ -49,3 +56,48 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+// purgeTrash periodically hard-deletes movies that have been in the trash for
+// longer than the retention period, until stop is closed.
+func (app *application) purgeTrash(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := app.models.Movies.Purge(app.config.trash.retention)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+			if n > 0 {
+				app.logger.PrintInfo("purged deleted movies", map[string]string{"count": strconv.FormatInt(n, 10)})
+			}
+		}
+	}
+}
+
+// pruneLoginAttempts periodically deletes failed login counters that have
+// gone stale, so that keys from sprayed emails and addresses don't pile up,
+// until stop is closed.
+func (app *application) pruneLoginAttempts(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.config.lockout.pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := app.models.LoginAttempts.Prune(app.config.lockout.window)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+			if n > 0 {
+				app.logger.PrintInfo("pruned login attempts", map[string]string{"count": strconv.FormatInt(n, 10)})
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listSessionsHandler(c *gin.Context) {
+	user := app.contextGetUser(c)
+	sessions, err := app.models.Tokens.GetSessionsForUser(user.ID, data.HashToken(app.contextGetToken(c)))
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"sessions": sessions}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteSessionHandler(c *gin.Context) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	user := app.contextGetUser(c)
+	if err := app.models.Tokens.DeleteSessionForUser(user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "session successfully revoked"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/jwt"
 	"github.com/Sukrati192/greenlight/internal/validator"
 	"github.com/gin-gonic/gin"
+	"github.com/tomasen/realip"
+	"golang.org/x/time/rate"
 )
 
 func (app *application) createAuthentication(c *gin.Context) {
@@ -26,11 +32,31 @@ func (app *application) createAuthentication(c *gin.Context) {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
+	ip := realip.FromRequest(c.Request)
+	ipKey, emailKey := "ip:"+ip, "email:"+strings.ToLower(input.Email)
+	ipAttempt, err := app.models.LoginAttempts.Get(ipKey)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if locked, retryAfter := ipAttempt.Locked(time.Now()); locked {
+		app.tooManyLoginAttemptsResponse(c, retryAfter)
+		return
+	}
+	emailAttempt, err := app.models.LoginAttempts.Get(emailKey)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if locked, retryAfter := emailAttempt.Locked(time.Now()); locked {
+		app.accountLockedResponse(c, retryAfter)
+		return
+	}
 	user, err := app.models.Users.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.invalidCredentialsResponse(c)
+			app.recordFailedLogin(c, input.Email, ip)
 		default:
 			app.serverErrorResponse(c, err)
 		}
@@ -39,17 +65,358 @@ func (app *application) createAuthentication(c *gin.Context) {
 	match, err := user.Password.Matches(input.Password)
 	if err != nil {
 		app.serverErrorResponse(c, err)
+		return
 	}
 	if !match {
+		app.recordFailedLogin(c, input.Email, ip)
+		return
+	}
+	app.completeLogin(c, user)
+}
+
+// completeLogin is called once a user has proven who they are. It responds
+// with an MFA challenge if the user has two-factor authentication enabled and
+// with a new pair of session tokens otherwise.
+func (app *application) completeLogin(c *gin.Context, user *data.User) {
+	twoFactor, err := app.models.TwoFactor.GetForUser(user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		token, err := app.models.Tokens.New(user.ID, 5*time.Minute, data.ScopeMFAPending)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if err := app.writeJSON(c, http.StatusOK, envelope{"mfa_token": token}, nil); err != nil {
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.LoginAttempts.Reset("email:" + strings.ToLower(user.Email)); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	family, err := data.NewTokenFamily()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	token, refreshToken, err := app.newSessionTokens(c, user, family)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err = app.writeJSON(c, http.StatusOK, envelope{"authentication_token": token, "refresh_token": refreshToken}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// recordFailedLogin counts a failed login against both the email address and
+// the client IP and sends the appropriate response, which is a lockout if the
+// failure tipped either counter over its threshold.
+func (app *application) recordFailedLogin(c *gin.Context, email, ip string) {
+	policies := []struct {
+		key    string
+		policy data.LockoutPolicy
+	}{
+		{"email:" + strings.ToLower(email), data.LockoutPolicy{Threshold: app.config.lockout.emailThreshold, Duration: app.config.lockout.duration, Window: app.config.lockout.window}},
+		{"ip:" + ip, data.LockoutPolicy{Threshold: app.config.lockout.ipThreshold, Duration: app.config.lockout.duration, Window: app.config.lockout.window}},
+	}
+	var lockedKey string
+	var retryAfter time.Duration
+	for _, p := range policies {
+		attempt, err := app.models.LoginAttempts.RecordFailure(p.key, p.policy)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if locked, d := attempt.Locked(time.Now()); locked {
+			app.logger.PrintInfo("login locked out", map[string]string{
+				"key":          p.key,
+				"failures":     strconv.Itoa(attempt.Failures),
+				"locked_until": attempt.LockedUntil.UTC().Format(time.RFC3339),
+				"ip":           ip,
+			})
+			if lockedKey == "" {
+				lockedKey, retryAfter = p.key, d
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(lockedKey, "email:"):
+		app.accountLockedResponse(c, retryAfter)
+	case strings.HasPrefix(lockedKey, "ip:"):
+		app.tooManyLoginAttemptsResponse(c, retryAfter)
+	default:
 		app.invalidCredentialsResponse(c)
+	}
+}
+
+func (app *application) newSessionTokens(c *gin.Context, user *data.User, family string) (*data.Token, *data.Token, error) {
+	ip, userAgent := realip.FromRequest(c.Request), c.Request.UserAgent()
+	var token *data.Token
+	var err error
+	if app.signer != nil {
+		token, err = app.newSignedToken(user, family, ip, userAgent)
+	} else {
+		token, err = app.models.Tokens.NewSession(user.ID, app.config.tokens.accessTTL, data.ScopeAuthentication, family, ip, userAgent)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := app.models.Tokens.NewSession(user.ID, app.config.tokens.refreshTTL, data.ScopeRefresh, family, ip, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, refreshToken, nil
+}
+
+// newSignedToken issues a self-contained access token. A row is still written
+// for it so that it appears in the session list and logging out revokes its
+// refresh token, but authenticate() never reads that row back.
+func (app *application) newSignedToken(user *data.User, family, ip, userAgent string) (*data.Token, error) {
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiry := now.Add(app.config.tokens.accessTTL)
+	plaintext, err := app.signer.Sign(jwt.Claims{
+		Subject:     user.ID,
+		Activated:   user.Activated,
+		Permissions: permissions,
+		IssuedAt:    now.Unix(),
+		Expiry:      expiry.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	token := &data.Token{
+		Plaintext: plaintext,
+		Hash:      data.HashToken(plaintext),
+		UserID:    user.ID,
+		Expiry:    expiry,
+		Scope:     data.ScopeAuthentication,
+		Family:    family,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+	err = app.models.Tokens.Insert(token)
+	return token, err
+}
+
+func (app *application) refreshAuthenticationHandler(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, input.RefreshToken)
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	used, err := app.models.Tokens.UseRefreshToken(input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidRefreshTokenResponse(c)
+		case errors.Is(err, data.ErrTokenReused):
+			app.logger.PrintInfo("refresh token reused, token family revoked", map[string]string{
+				"ip": realip.FromRequest(c.Request),
+			})
+			app.invalidRefreshTokenResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.Tokens.DeleteFamily(data.ScopeAuthentication, used.Family); err != nil {
+		app.serverErrorResponse(c, err)
 		return
 	}
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	user, err := app.models.Users.Get(used.UserID)
 	if err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
-	if err = app.writeJSON(c, http.StatusOK, envelope{"authentication_token": token}, nil); err != nil {
+	token, refreshToken, err := app.newSessionTokens(c, user, used.Family)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err = app.writeJSON(c, http.StatusOK, envelope{"authentication_token": token, "refresh_token": refreshToken}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createPasswordResetTokenHandler(c *gin.Context) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	// The response is identical whether or not the address belongs to an
+	// account so that this endpoint cannot be used to enumerate users.
+	env := envelope{"message": "if an account with that email address exists, you will receive password reset instructions shortly"}
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			if err := app.writeJSON(c, http.StatusAccepted, env, nil); err != nil {
+				app.serverErrorResponse(c, err)
+			}
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if user.Activated {
+		token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		app.background(func() {
+			data := map[string]interface{}{
+				"passwordResetToken": token.Plaintext,
+			}
+			if err := app.mailer.Send(user.Email, "token_password_reset.html", data); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		})
+	}
+	if err := app.writeJSON(c, http.StatusAccepted, env, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) createActivationTokenHandler() gin.HandlerFunc {
+	type recipient struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+	var (
+		mu         sync.Mutex
+		recipients = make(map[string]*recipient)
+	)
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			mu.Lock()
+			for email, recipient := range recipients {
+				if time.Since(recipient.lastSeen) > 15*time.Minute {
+					delete(recipients, email)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+	return func(c *gin.Context) {
+		var input struct {
+			Email string `json:"email"`
+		}
+		if err := app.readJSON(c, &input); err != nil {
+			app.badRequestResponse(c, err)
+			return
+		}
+		v := validator.New()
+		if data.ValidateEmail(v, input.Email); !v.Valid() {
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		key := strings.ToLower(input.Email)
+		mu.Lock()
+		if _, found := recipients[key]; !found {
+			recipients[key] = &recipient{limiter: rate.NewLimiter(rate.Every(5*time.Minute), 1)}
+		}
+		recipients[key].lastSeen = time.Now()
+		allowed := recipients[key].limiter.Allow()
+		mu.Unlock()
+		if !allowed {
+			app.rateLimitExceededResponse(c)
+			return
+		}
+		// As with password resets, the response doesn't reveal whether the
+		// address belongs to an account or whether it is already activated.
+		env := envelope{"message": "if an unactivated account with that email address exists, you will receive activation instructions shortly"}
+		user, err := app.models.Users.GetByEmail(input.Email)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				if err := app.writeJSON(c, http.StatusAccepted, env, nil); err != nil {
+					app.serverErrorResponse(c, err)
+				}
+			default:
+				app.serverErrorResponse(c, err)
+			}
+			return
+		}
+		if !user.Activated {
+			if err := app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID); err != nil {
+				app.serverErrorResponse(c, err)
+				return
+			}
+			token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+			if err != nil {
+				app.serverErrorResponse(c, err)
+				return
+			}
+			app.background(func() {
+				data := map[string]interface{}{
+					"activationToken": token.Plaintext,
+				}
+				if err := app.mailer.Send(user.Email, "token_activation.html", data); err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			})
+		}
+		if err := app.writeJSON(c, http.StatusAccepted, env, nil); err != nil {
+			app.serverErrorResponse(c, err)
+		}
+	}
+}
+
+func (app *application) deleteAuthenticationTokenHandler(c *gin.Context) {
+	token, scope := app.contextGetToken(c), data.ScopeAuthentication
+	if app.contextGetActor(c) != 0 {
+		scope = data.ScopeImpersonation
+	}
+	if err := app.models.Tokens.DeleteByHash(scope, data.HashToken(token)); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "authentication token successfully revoked"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteAllAuthenticationTokensHandler(c *gin.Context) {
+	user := app.contextGetUser(c)
+	for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh} {
+		if err := app.models.Tokens.DeleteAllForUser(scope, user.ID); err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "all authentication tokens successfully revoked"}, nil); err != nil {
 		app.serverErrorResponse(c, err)
 	}
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/totp"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/tomasen/realip"
+)
+
+const totpIssuer = "Greenlight"
+
+func (app *application) enrollTOTPHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.TwoFactor.SetSecret(user.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			v := validator.New()
+			v.AddError("totp", "two-factor authentication is already enabled")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	env := envelope{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	}
+	if err := app.writeJSON(c, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) verifyTOTPHandler(c *gin.Context) {
+	user := app.contextGetUser(c)
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateTOTPCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	twoFactor, err := app.models.TwoFactor.GetForUser(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("totp", "two-factor enrollment has not been started")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if twoFactor.Enabled {
+		v.AddError("totp", "two-factor authentication is already enabled")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	step, ok := totp.Validate(twoFactor.Secret, input.Code, time.Now())
+	if ok {
+		ok, err = app.models.TwoFactor.UseStep(user.ID, step)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	if !ok {
+		v.AddError("code", "is invalid")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	recoveryCodes, err := data.GenerateRecoveryCodes(10)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.TwoFactor.Enable(user.ID, recoveryCodes); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"recovery_codes": recoveryCodes}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) disableTOTPHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(input.Password != "", "password", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !match {
+		app.invalidCredentialsResponse(c)
+		return
+	}
+	if err := app.models.TwoFactor.Disable(user.ID); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "two-factor authentication successfully disabled"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// createMFAAuthentication completes a login for an account with two-factor
+// authentication enabled, exchanging the mfa_token returned by
+// createAuthentication and a TOTP or recovery code for session tokens.
+func (app *application) createMFAAuthentication(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, input.MFAToken)
+	switch {
+	case input.Code == "" && input.RecoveryCode == "":
+		v.AddError("code", "must be provided")
+	case input.Code != "":
+		data.ValidateTOTPCode(v, input.Code)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	user, err := app.models.Users.GetForToken(data.ScopeMFAPending, input.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	ip := realip.FromRequest(c.Request)
+	emailKey := "email:" + strings.ToLower(user.Email)
+	attempt, err := app.models.LoginAttempts.Get(emailKey)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if locked, retryAfter := attempt.Locked(time.Now()); locked {
+		app.accountLockedResponse(c, retryAfter)
+		return
+	}
+	twoFactor, err := app.models.TwoFactor.GetForUser(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	// An enrollment that was never verified doesn't count as a second factor.
+	if !twoFactor.Enabled {
+		app.invalidAuthenticationResponse(c)
+		return
+	}
+	var valid bool
+	if input.Code != "" {
+		if step, ok := totp.Validate(twoFactor.Secret, input.Code, time.Now()); ok {
+			valid, err = app.models.TwoFactor.UseStep(user.ID, step)
+			if err != nil {
+				app.serverErrorResponse(c, err)
+				return
+			}
+		}
+	} else {
+		valid, err = app.models.TwoFactor.UseRecoveryCode(user.ID, input.RecoveryCode)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	if !valid {
+		app.recordFailedLogin(c, user.Email, ip)
+		return
+	}
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopeMFAPending, user.ID); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.LoginAttempts.Reset(emailKey); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	family, err := data.NewTokenFamily()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	token, refreshToken, err := app.newSessionTokens(c, user, family)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err = app.writeJSON(c, http.StatusOK, envelope{"authentication_token": token, "refresh_token": refreshToken}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Sukrati192/greenlight/internal/data"
@@ -10,6 +11,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// reauthenticationWindow is how recently a user who signs in through an
+// identity provider must have logged in to delete their account without a
+// password.
+const reauthenticationWindow = 5 * time.Minute
+
 func (app *application) registerUserHandler(c *gin.Context) {
 	var input struct {
 		Name     string `json:"name"`
@@ -44,7 +50,7 @@ func (app *application) registerUserHandler(c *gin.Context) {
 		}
 		return
 	}
-	if err := app.models.Permissions.AddForUser(user.ID, "movies:read"); err != nil {
+	if err := app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write"); err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
@@ -109,3 +115,407 @@ func (app *application) activateUserHandler(c *gin.Context) {
 		app.serverErrorResponse(c, err)
 	}
 }
+
+func (app *application) updateUserPasswordHandler(c *gin.Context) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	data.ValidatePasswordPlainText(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := user.Password.Set(input.Password); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.Users.Update(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh} {
+		if err := app.models.Tokens.DeleteAllForUser(scope, user.ID); err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) showCurrentUserHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"user": user, "permissions": permissions}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) updateCurrentUserHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name            *string `json:"name"`
+		Password        *string `json:"password"`
+		CurrentPassword *string `json:"current_password"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	if input.Password != nil {
+		if input.CurrentPassword == nil || *input.CurrentPassword == "" {
+			v.AddError("current_password", "must be provided to change the password")
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		match, err := user.Password.Matches(*input.CurrentPassword)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if !match {
+			v.AddError("current_password", "is incorrect")
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		if err := user.Password.Set(*input.Password); err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+	if data.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Users.Update(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) requestEmailChangeHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	v.Check(input.Password != "", "password", "must be provided")
+	v.Check(!strings.EqualFold(input.Email, user.Email), "email", "must be different from the current email address")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !match {
+		app.invalidCredentialsResponse(c)
+		return
+	}
+	_, err = app.models.Users.GetByEmail(input.Email)
+	switch {
+	case err == nil:
+		v.AddError("email", "a user with this email already exists")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopeEmailChange, user.ID); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	token, err := app.models.Tokens.NewForEmail(user.ID, 24*time.Hour, data.ScopeEmailChange, input.Email)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	app.background(func() {
+		data := map[string]interface{}{
+			"emailChangeToken": token.Plaintext,
+		}
+		if err := app.mailer.Send(token.Email, "token_email_change.html", data); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+	env := envelope{"message": "an email will be sent to the new address containing confirmation instructions"}
+	if err := app.writeJSON(c, http.StatusAccepted, env, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) confirmEmailChangeHandler(c *gin.Context) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	user, newEmail, err := app.models.Users.GetForEmailChangeToken(input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	oldEmail := user.Email
+	user.Email = newEmail
+	if err := app.models.Users.Update(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email already exists")
+			app.failedValidationResponse(c, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopeEmailChange, user.ID); err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	app.background(func() {
+		data := map[string]interface{}{
+			"userID":   user.ID,
+			"newEmail": user.Email,
+		}
+		if err := app.mailer.Send(oldEmail, "user_email_changed.html", data); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+	if err := app.writeJSON(c, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// deleteCurrentUserHandler deletes the user's account after checking their
+// password or, if they sign in through an identity provider and send no
+// password, that they logged in within the last few minutes.
+func (app *application) deleteCurrentUserHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if input.Password == "" {
+		if !app.requireRecentLogin(c, user) {
+			return
+		}
+	} else {
+		v := validator.New()
+		if data.ValidatePasswordPlainText(v, input.Password); !v.Valid() {
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		match, err := user.Password.Matches(input.Password)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if !match {
+			app.invalidCredentialsResponse(c)
+			return
+		}
+	}
+	entry := app.auditEntry(c, "user.delete", user.ID, nil)
+	if err := app.models.Users.Delete(user.ID, entry); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	app.background(func() {
+		data := map[string]interface{}{
+			"userID": user.ID,
+		}
+		if err := app.mailer.Send(user.Email, "user_deleted.html", data); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "your account was successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// requireRecentLogin stands in for a password check for users who sign in
+// through an identity provider and may never have seen their password: it
+// passes if the current session was logged in within reauthenticationWindow.
+// Otherwise it sends the response itself.
+func (app *application) requireRecentLogin(c *gin.Context, user *data.User) bool {
+	identities, err := app.models.Identities.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return false
+	}
+	if len(identities) == 0 {
+		v := validator.New()
+		v.AddError("password", "must be provided")
+		app.failedValidationResponse(c, v.Errors)
+		return false
+	}
+	started, err := app.models.Tokens.GetSessionStart(data.HashToken(app.contextGetToken(c)))
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(c, err)
+		return false
+	}
+	if err != nil || time.Since(started) > reauthenticationWindow {
+		app.reauthenticationRequiredResponse(c)
+		return false
+	}
+	return true
+}
+
+// exportCurrentUserHandler returns everything we hold about the user as a
+// single JSON document. Secrets such as token and key hashes are left out.
+func (app *application) exportCurrentUserHandler(c *gin.Context) {
+	user, ok := app.currentUser(c)
+	if !ok {
+		return
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	sessions, err := app.models.Tokens.GetSessionsForUser(user.ID, data.HashToken(app.contextGetToken(c)))
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	apiKeys, err := app.models.APIKeys.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	identities, err := app.models.Identities.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	reviews, err := app.models.Reviews.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	watchlist, err := app.models.Watchlist.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	revisions, err := app.models.Revisions.GetAllForActor(user.ID)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	twoFactor, err := app.models.TwoFactor.GetForUser(user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	export := envelope{
+		"exported_at":        time.Now().UTC(),
+		"user":               user,
+		"permissions":        permissions,
+		"roles":              roles,
+		"sessions":           sessions,
+		"api_keys":           apiKeys,
+		"identities":         identities,
+		"reviews":            reviews,
+		"watchlist":          watchlist,
+		"movie_revisions":    revisions,
+		"two_factor_enabled": twoFactor != nil && twoFactor.Enabled,
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", `attachment; filename="greenlight-export.json"`)
+	if err := app.writeJSON(c, http.StatusOK, export, headers); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/data"
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+func (app *application) listWatchlistHandler(c *gin.Context) {
+	var filters data.Filters
+	v := validator.New()
+	qs := c.Request.URL.Query()
+	var watched *bool
+	if s := qs.Get("watched"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			v.AddError("watched", "must be true or false")
+		}
+		watched = &b
+	}
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-added_at")
+	filters.SortSafeList = []string{"added_at", "title", "year", "watched_at", "-added_at", "-title", "-year", "-watched_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	items, metadata, err := app.models.Watchlist.GetAll(app.contextGetUser(c).ID, watched, filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"watchlist": items, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) addWatchlistItemHandler(c *gin.Context) {
+	var input struct {
+		MovieID   int64      `json:"movie_id"`
+		Watched   bool       `json:"watched"`
+		WatchedAt *time.Time `json:"watched_at"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	item := &data.WatchlistItem{
+		UserID:    app.contextGetUser(c).ID,
+		MovieID:   input.MovieID,
+		Watched:   input.Watched,
+		WatchedAt: input.WatchedAt,
+	}
+	if item.Watched && item.WatchedAt == nil {
+		now := time.Now()
+		item.WatchedAt = &now
+	}
+	v := validator.New()
+	v.Check(item.MovieID > 0, "movie_id", "must be provided")
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if _, err := app.models.Movies.Get(item.MovieID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "must refer to an existing movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.models.Watchlist.Insert(item); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlistItem):
+			v.AddError("movie_id", "is already on your watchlist")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusCreated, envelope{"watchlist_item": item}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+// updateWatchlistItemHandler marks a movie as watched or unwatched. Marking it
+// watched without a date records the current time.
+func (app *application) updateWatchlistItemHandler(c *gin.Context) {
+	movieID, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	user := app.contextGetUser(c)
+	item, err := app.models.Watchlist.Get(user.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	var input struct {
+		Watched   *bool      `json:"watched"`
+		WatchedAt *time.Time `json:"watched_at"`
+	}
+	if err := app.readJSON(c, &input); err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if input.Watched != nil {
+		item.Watched = *input.Watched
+		if !item.Watched {
+			item.WatchedAt = nil
+		}
+	}
+	if input.WatchedAt != nil {
+		item.WatchedAt = input.WatchedAt
+	}
+	if item.Watched && item.WatchedAt == nil {
+		now := time.Now()
+		item.WatchedAt = &now
+	}
+	v := validator.New()
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+	if err := app.models.Watchlist.Update(item); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"watchlist_item": item}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
+
+func (app *application) deleteWatchlistItemHandler(c *gin.Context) {
+	movieID, err := app.readIDParam(c)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	if err := app.models.Watchlist.Delete(app.contextGetUser(c).ID, movieID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	if err := app.writeJSON(c, http.StatusOK, envelope{"message": "movie successfully removed from watchlist"}, nil); err != nil {
+		app.serverErrorResponse(c, err)
+	}
+}
@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than a session
+// token, so authenticate() can tell them apart without a lookup.
+const APIKeyPrefix = "glk_"
+
+type APIKey struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UserID      int64       `json:"-"`
+	Name        string      `json:"name"`
+	Plaintext   string      `json:"key,omitempty"`
+	Hash        []byte      `json:"-"`
+	Prefix      string      `json:"prefix"`
+	Permissions Permissions `json:"permissions"`
+	Expiry      *time.Time  `json:"expiry,omitempty"`
+	LastUsedAt  *time.Time  `json:"last_used_at,omitempty"`
+}
+
+func GenerateAPIKey(userID int64, name string, permissions Permissions, expiry *time.Time) (*APIKey, error) {
+	randomBytes := make([]byte, 20)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+	plaintext := APIKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	return &APIKey{
+		UserID:      userID,
+		Name:        name,
+		Plaintext:   plaintext,
+		Hash:        HashToken(plaintext),
+		Prefix:      plaintext[:len(APIKeyPrefix)+6],
+		Permissions: permissions,
+		Expiry:      expiry,
+	}, nil
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(strings.HasPrefix(plaintext, APIKeyPrefix), "key", "must be an API key")
+	v.Check(len(plaintext) == len(APIKeyPrefix)+32, "key", "must be 36 bytes long")
+}
+
+// ValidateAPIKey checks a new key. A key may only carry permissions that its
+// owner holds, so it can never be used to escalate privileges.
+func ValidateAPIKey(v *validator.Validator, key *APIKey, known, owner Permissions) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(key.Permissions) >= 1, "permissions", "must contain at least one permission")
+	v.Check(validator.Unique(key.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range key.Permissions {
+		ValidatePermissionCode(v, "permissions", code, known)
+		v.Check(owner.Include(code), "permissions", "contains permission you do not hold "+code)
+	}
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+type APIKeysInterface interface {
+	Insert(key *APIKey) error
+	GetAllForUser(userID int64) ([]*APIKey, error)
+	DeleteForUser(userID, id int64) error
+	GetForKey(plaintext string) (*User, *APIKey, error)
+	UpdateLastUsed(lastUsed map[int64]time.Time) error
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+func (m APIKeyModel) Insert(key *APIKey) error {
+	query := `INSERT INTO api_keys (user_id, name, hash, prefix, permissions, expiry) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+	args := []interface{}{key.UserID, key.Name, key.Hash, key.Prefix, pq.Array([]string(key.Permissions)), key.Expiry}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*APIKey, error) {
+	query := `SELECT id, created_at, user_id, name, prefix, permissions, expiry, last_used_at FROM api_keys
+	WHERE user_id = $1 ORDER BY id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.CreatedAt, &key.UserID, &key.Name, &key.Prefix,
+			pq.Array((*[]string)(&key.Permissions)), &key.Expiry, &key.LastUsedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (m APIKeyModel) DeleteForUser(userID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m APIKeyModel) GetForKey(plaintext string) (*User, *APIKey, error) {
+	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated,
+	users.version, api_keys.id, api_keys.created_at, api_keys.name, api_keys.prefix, api_keys.permissions,
+	api_keys.expiry, api_keys.last_used_at
+	FROM users INNER JOIN api_keys ON users.id=api_keys.user_id
+	WHERE api_keys.hash = $1 AND (api_keys.expiry IS NULL OR api_keys.expiry > NOW())`
+	var user User
+	var key APIKey
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, HashToken(plaintext)).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&key.ID,
+		&key.CreatedAt,
+		&key.Name,
+		&key.Prefix,
+		pq.Array((*[]string)(&key.Permissions)),
+		&key.Expiry,
+		&key.LastUsedAt,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	key.UserID = user.ID
+	return &user, &key, nil
+}
+
+func (m APIKeyModel) UpdateLastUsed(lastUsed map[int64]time.Time) error {
+	ids := make([]int64, 0, len(lastUsed))
+	times := make([]string, 0, len(lastUsed))
+	for id, t := range lastUsed {
+		ids = append(ids, id)
+		times = append(times, t.Format(time.RFC3339Nano))
+	}
+	query := `UPDATE api_keys SET last_used_at = usage.last_used_at
+	FROM unnest($1::bigint[], $2::timestamptz[]) AS usage(id, last_used_at)
+	WHERE api_keys.id = usage.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(ids), pq.Array(times))
+	return err
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type AuditEntry struct {
+	ID           int64                  `json:"id"`
+	CreatedAt    time.Time              `json:"created_at"`
+	ActorID      int64                  `json:"actor_id"`
+	TargetUserID *int64                 `json:"target_user_id,omitempty"`
+	Action       string                 `json:"action"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+type AuditInterface interface {
+	Insert(entry *AuditEntry) error
+	GetAll(targetUserID int64, filters Filters) ([]*AuditEntry, Metadata, error)
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+func (m AuditModel) Insert(entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertAudit writes entry as part of tx, so that an action and its audit
+// record are committed or rolled back together.
+func insertAudit(ctx context.Context, tx *sql.Tx, entry *AuditEntry) error {
+	details := []byte("{}")
+	if entry.Details != nil {
+		var err error
+		if details, err = json.Marshal(entry.Details); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO audit_log (actor_id, target_user_id, action, details) VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+	args := []interface{}{entry.ActorID, entry.TargetUserID, entry.Action, details}
+	return tx.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (m AuditModel) GetAll(targetUserID int64, filters Filters) ([]*AuditEntry, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, actor_id, target_user_id, action, details FROM audit_log
+	WHERE (target_user_id = $1 OR $1 = 0)
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, targetUserID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		if err := rows.Scan(&totalRecords, &entry.ID, &entry.CreatedAt, &entry.ActorID, &entry.TargetUserID,
+			&entry.Action, &details); err != nil {
+			return nil, Metadata{}, err
+		}
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
@@ -1,7 +1,12 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"slices"
 	"strings"
 
 	"github.com/Sukrati192/greenlight/internal/validator"
@@ -12,6 +17,11 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+
+	// Cursor, when set, pages from a cursor returned in an earlier response
+	// instead of by page number.
+	Cursor    string
+	SkipTotal bool
 }
 
 type Metadata struct {
@@ -20,6 +30,46 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque cursors handed out in Metadata.
+// It records the sort it was issued for and the sort value and id of the row
+// to continue from, in the given direction.
+type cursor struct {
+	Sort      string `json:"sort"`
+	Value     string `json:"value"`
+	ID        int64  `json:"id"`
+	Direction string `json:"direction"`
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(js, &c); err != nil {
+		return nil, errInvalidCursor
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -28,6 +78,13 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 	v.Check(validator.In(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "must be a cursor from a previous response")
+		if err == nil {
+			v.Check(c.Sort == f.Sort, "cursor", "does not match the sort order")
+		}
+	}
 }
 
 func (f *Filters) sortColumn() string {
@@ -46,6 +103,64 @@ func (f *Filters) sortDirection() string {
 	return "ASC"
 }
 
+// cursor returns the decoded cursor, or nil when paging by page number. The
+// filters must have been validated.
+func (f *Filters) cursor() *cursor {
+	if f.Cursor == "" {
+		return nil
+	}
+	c, err := decodeCursor(f.Cursor)
+	if err != nil {
+		panic("unvalidated cursor: " + f.Cursor)
+	}
+	return c
+}
+
+// keyset returns the condition selecting the rows beyond the cursor, comparing
+// the sort expression and id column against placeholders $n and $n+1, and the
+// arguments for them. Without a cursor it matches every row.
+func (f *Filters) keyset(expr, idColumn string, n int) (string, []interface{}) {
+	c := f.cursor()
+	if c == nil {
+		return "TRUE", nil
+	}
+	forward := c.Direction == cursorNext
+	op, idOp := "<", "<"
+	if forward == (f.sortDirection() == "ASC") {
+		op = ">"
+	}
+	if forward {
+		idOp = ">"
+	}
+	condition := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND %[4]s %[5]s $%[6]d))", expr, op, n, idColumn, idOp, n+1)
+	return condition, []interface{}{c.Value, c.ID}
+}
+
+// keysetOrder returns the sort and id directions to query in, which are
+// reversed when paging backwards from a cursor.
+func (f *Filters) keysetOrder() (string, string) {
+	if c := f.cursor(); c != nil && c.Direction == cursorPrev {
+		if f.sortDirection() == "DESC" {
+			return "ASC", "DESC"
+		}
+		return "DESC", "DESC"
+	}
+	return f.sortDirection(), "ASC"
+}
+
+// keysetLimit is one more than the page size, so that the query reveals
+// whether another page follows.
+func (f *Filters) keysetLimit() int {
+	return f.PageSize + 1
+}
+
+func (f *Filters) keysetOffset() int {
+	if f.Cursor != "" {
+		return 0
+	}
+	return f.offset()
+}
+
 func (f *Filters) limit() int {
 	return f.PageSize
 }
@@ -66,3 +181,43 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+// paginate trims the extra row fetched by keysetLimit, restores the requested
+// order of rows fetched backwards and sets the cursors either side of the
+// page. key returns a row's sort value and id.
+func paginate[T any](rows []T, totalRecords int, f Filters, key func(T) (interface{}, int64)) ([]T, Metadata) {
+	c := f.cursor()
+	more := len(rows) > f.PageSize
+	if more {
+		rows = rows[:f.PageSize]
+	}
+	var metadata Metadata
+	switch {
+	case c == nil && !f.SkipTotal:
+		metadata = calculateMetadata(totalRecords, f.Page, f.PageSize)
+	case c == nil:
+		metadata = Metadata{CurrentPage: f.Page, PageSize: f.PageSize, FirstPage: 1}
+	default:
+		metadata = Metadata{PageSize: f.PageSize, TotalRecords: totalRecords}
+	}
+	if len(rows) == 0 {
+		return rows, metadata
+	}
+	hasNext, hasPrev := more, f.Page > 1
+	if c != nil {
+		hasNext, hasPrev = more, true
+		if c.Direction == cursorPrev {
+			slices.Reverse(rows)
+			hasNext, hasPrev = true, more
+		}
+	}
+	if hasNext {
+		value, id := key(rows[len(rows)-1])
+		metadata.NextCursor = encodeCursor(cursor{Sort: f.Sort, Value: fmt.Sprint(value), ID: id, Direction: cursorNext})
+	}
+	if hasPrev {
+		value, id := key(rows[0])
+		metadata.PrevCursor = encodeCursor(cursor{Sort: f.Sort, Value: fmt.Sprint(value), ID: id, Direction: cursorPrev})
+	}
+	return rows, metadata
+}
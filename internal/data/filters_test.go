@@ -0,0 +1,55 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+func TestKeyset(t *testing.T) {
+	next := encodeCursor(cursor{Sort: "-year", Value: "1999", ID: 7, Direction: cursorNext})
+	f := Filters{PageSize: 2, Sort: "-year", SortSafeList: []string{"-year"}, Cursor: next}
+	if condition, args := f.keyset("year", "id", 7); condition != "(year < $7 OR (year = $7 AND id > $8))" || args[0] != "1999" || args[1] != int64(7) {
+		t.Errorf("keyset() = %q, %v", condition, args)
+	}
+
+	f.Cursor = encodeCursor(cursor{Sort: "-year", Value: "1999", ID: 7, Direction: cursorPrev})
+	if condition, _ := f.keyset("year", "id", 7); condition != "(year > $7 OR (year = $7 AND id < $8))" {
+		t.Errorf("keyset() backwards = %q", condition)
+	}
+	if sort, id := f.keysetOrder(); sort != "ASC" || id != "DESC" {
+		t.Errorf("keysetOrder() backwards = %s, %s", sort, id)
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	f := Filters{Page: 1, PageSize: 2, Sort: "id", SortSafeList: []string{"id", "-id"}}
+	for _, c := range []string{"not a cursor", encodeCursor(cursor{Sort: "-id", Direction: cursorNext})} {
+		f.Cursor = c
+		v := validator.New()
+		if ValidateFilters(v, f); v.Valid() {
+			t.Errorf("ValidateFilters() accepted cursor %q", c)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	key := func(id int64) (interface{}, int64) { return id, id }
+	f := Filters{Page: 1, PageSize: 2, Sort: "id"}
+	rows, metadata := paginate([]int64{1, 2, 3}, 5, f, key)
+	if len(rows) != 2 || metadata.NextCursor == "" || metadata.PrevCursor != "" || metadata.LastPage != 3 {
+		t.Fatalf("paginate() = %v, %+v", rows, metadata)
+	}
+
+	f.Cursor = metadata.NextCursor
+	rows, metadata = paginate([]int64{3, 4, 5}, 5, f, key)
+	if len(rows) != 2 || metadata.NextCursor == "" || metadata.PrevCursor == "" {
+		t.Fatalf("paginate() next page = %v, %+v", rows, metadata)
+	}
+
+	f.Cursor = metadata.PrevCursor
+	rows, metadata = paginate([]int64{2, 1}, 5, f, key)
+	if len(rows) != 2 || rows[0] != 1 || metadata.PrevCursor != "" || metadata.NextCursor == "" {
+		t.Errorf("paginate() previous page = %v, %+v", rows, metadata)
+	}
+}
@@ -0,0 +1,353 @@
+package data
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+
+	GenreSlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+	nonSlugRX = regexp.MustCompile("[^a-z0-9]+")
+)
+
+const ErrPsqlDuplicateGenre = `pq: duplicate key value violates unique constraint "genres_slug_key"`
+
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	Aliases    []string  `json:"aliases"`
+	MovieCount int       `json:"movie_count"`
+	Version    int32     `json:"version"`
+}
+
+// Slugify normalises a genre the same way the backfill migration does, so
+// "Sci-Fi", "sci fi" and "SCI_FI" all become "sci-fi". Genres with no ASCII
+// letters or digits get a slug derived from a hash of their spelling, so that
+// they stay distinct.
+func Slugify(s string) string {
+	slug := strings.Trim(nonSlugRX.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if slug == "" && strings.TrimSpace(s) != "" {
+		sum := md5.Sum([]byte(s))
+		slug = "genre-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return slug
+}
+
+// Genres is the managed genre vocabulary.
+type Genres []*Genre
+
+// Resolve returns the slug of the genre whose slug, name or alias matches s
+// once normalised.
+func (g Genres) Resolve(s string) (string, bool) {
+	key := Slugify(s)
+	if key == "" {
+		return "", false
+	}
+	for _, genre := range g {
+		if genre.Slug == key || Slugify(genre.Name) == key {
+			return genre.Slug, true
+		}
+		for _, alias := range genre.Aliases {
+			if Slugify(alias) == key {
+				return genre.Slug, true
+			}
+		}
+	}
+	return "", false
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre, vocabulary Genres) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(genre.Slug, GenreSlugRX), "slug", "must contain only lowercase letters, digits and single dashes")
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 entries")
+	v.Check(validator.Unique(genre.Aliases), "aliases", "must not contain duplicate values")
+	for _, alias := range genre.Aliases {
+		v.Check(Slugify(alias) != "" && len(alias) <= 100, "aliases", "must contain aliases between 1 and 100 bytes long")
+	}
+	// Every spelling must resolve to exactly one genre.
+	for _, s := range append([]string{genre.Slug, genre.Name}, genre.Aliases...) {
+		for _, other := range vocabulary {
+			if other.ID == genre.ID {
+				continue
+			}
+			if slug, ok := (Genres{other}).Resolve(s); ok {
+				v.AddError("aliases", "conflicts with genre "+slug)
+			}
+		}
+	}
+}
+
+type GenresInterface interface {
+	Insert(genre *Genre, entry *AuditEntry) error
+	Get(id int64) (*Genre, error)
+	GetAll() (Genres, error)
+	Update(genre *Genre, oldSlug string, actorID int64, entry *AuditEntry) error
+	Delete(id int64, entry *AuditEntry) error
+	Merge(source, target *Genre, actorID int64, entry *AuditEntry) error
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a genre and records entry in the audit log in the same
+// transaction.
+func (m GenreModel) Insert(genre *Genre, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO genres (slug, name, aliases) VALUES ($1, $2, $3) RETURNING id, created_at, version`
+	if err := tx.QueryRowContext(ctx, query, genre.Slug, genre.Name, pq.Array(genre.aliases())).Scan(
+		&genre.ID, &genre.CreatedAt, &genre.Version,
+	); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateGenre:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	genres, err := m.query(`WHERE genres.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(genres) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return genres[0], nil
+}
+
+func (m GenreModel) GetAll() (Genres, error) {
+	return m.query("")
+}
+
+func (m GenreModel) query(where string, args ...interface{}) (Genres, error) {
+	query := `SELECT genres.id, genres.created_at, genres.slug, genres.name, genres.aliases, genres.version,
+	(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL)
+	FROM genres ` + where + ` ORDER BY genres.name`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	genres := Genres{}
+	for rows.Next() {
+		var genre Genre
+		if err := rows.Scan(&genre.ID, &genre.CreatedAt, &genre.Slug, &genre.Name, pq.Array(&genre.Aliases),
+			&genre.Version, &genre.MovieCount); err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// Update saves the genre and, if its slug changed from oldSlug, renames it in
+// every movie in the same transaction, recording a revision of each movie
+// made by actorID. entry is recorded in the audit log in that transaction too.
+func (m GenreModel) Update(genre *Genre, oldSlug string, actorID int64, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `UPDATE genres SET slug = $1, name = $2, aliases = $3, version = version + 1
+	WHERE id = $4 AND version = $5 RETURNING version`
+	args := []interface{}{genre.Slug, genre.Name, pq.Array(genre.aliases()), genre.ID, genre.Version}
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateGenre:
+			return ErrDuplicateGenre
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	if oldSlug != genre.Slug {
+		if err := replaceMovieGenre(ctx, tx, oldSlug, genre.Slug, actorID); err != nil {
+			return err
+		}
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Merge folds source into target: movies using source are moved to target,
+// source's slug, name and aliases become aliases of target, and source is
+// deleted, all in one transaction with entry's audit record. Each movie
+// changed gets a revision made by actorID.
+func (m GenreModel) Merge(source, target *Genre, actorID int64, entry *AuditEntry) error {
+	target.Aliases = mergeAliases(target, source)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := replaceMovieGenre(ctx, tx, source.Slug, target.Slug, actorID); err != nil {
+		return err
+	}
+	query := `DELETE FROM genres WHERE id = $1 AND version = $2`
+	result, err := tx.ExecContext(ctx, query, source.ID, source.Version)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+	query = `UPDATE genres SET aliases = $1, version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, pq.Array(target.aliases()), target.ID, target.Version).Scan(&target.Version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mergeAliases returns target's aliases plus every spelling of source that
+// doesn't already resolve to target.
+func mergeAliases(target, source *Genre) []string {
+	aliases := append([]string{}, target.Aliases...)
+	for _, s := range append([]string{source.Slug, source.Name}, source.Aliases...) {
+		if _, ok := (Genres{{Slug: target.Slug, Name: target.Name, Aliases: aliases}}).Resolve(s); !ok {
+			aliases = append(aliases, s)
+		}
+	}
+	return aliases
+}
+
+// replaceMovieGenre swaps oldSlug for newSlug in every movie that uses it,
+// keeping only the first if a movie ends up with newSlug twice, and records an
+// update revision of each movie changed.
+func replaceMovieGenre(ctx context.Context, tx *sql.Tx, oldSlug, newSlug string, actorID int64) error {
+	query := `UPDATE movies SET genres = ARRAY(
+		SELECT genre FROM unnest(array_replace(movies.genres, $1, $2)) WITH ORDINALITY AS g(genre, position)
+		GROUP BY genre ORDER BY min(position)
+	), version = version + 1
+	WHERE genres @> ARRAY[$1]
+	RETURNING id, title, year, runtime, genres, version`
+	rows, err := tx.QueryContext(ctx, query, oldSlug, newSlug)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		if err := rows.Scan(&movie.ID, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version); err != nil {
+			return err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	for _, movie := range movies {
+		if err := recordRevision(ctx, tx, movie, RevisionUpdate, actorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a genre. Genres that are still used by a movie can't be
+// deleted, since that would leave movies with an unknown genre.
+// Delete removes a genre that no movie uses and records entry in the audit
+// log in the same transaction.
+func (m GenreModel) Delete(id int64, entry *AuditEntry) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `DELETE FROM genres WHERE id = $1
+	AND NOT EXISTS (SELECT 1 FROM movies WHERE movies.genres @> ARRAY[genres.slug])`
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM genres WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRecordNotFound
+		}
+		return ErrGenreInUse
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (g *Genre) aliases() []string {
+	if g.Aliases == nil {
+		return []string{}
+	}
+	return g.Aliases
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+func TestGenresResolve(t *testing.T) {
+	genres := Genres{
+		{ID: 1, Slug: "sci-fi", Name: "Science Fiction", Aliases: []string{"SF"}},
+		{ID: 2, Slug: "drama", Name: "Drama"},
+	}
+	tests := []struct {
+		name  string
+		genre string
+		want  string
+		ok    bool
+	}{
+		{"slug", "sci-fi", "sci-fi", true},
+		{"different case", "Sci-Fi", "sci-fi", true},
+		{"different separator", "sci fi", "sci-fi", true},
+		{"name", "science fiction", "sci-fi", true},
+		{"alias", "sf", "sci-fi", true},
+		{"unknown", "western", "", false},
+		{"empty", " - ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := genres.Resolve(tt.genre)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Genres.Resolve(%q) = %q, %v, want %q, %v", tt.genre, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateMovieGenres(t *testing.T) {
+	genres := Genres{{ID: 1, Slug: "sci-fi", Name: "Science Fiction"}, {ID: 2, Slug: "drama", Name: "Drama"}}
+	movie := &Movie{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"Science Fiction", "drama"}}
+	v := validator.New()
+	if ValidateMovie(v, movie, genres); !v.Valid() {
+		t.Fatalf("ValidateMovie() errors = %v", v.Errors)
+	}
+	if movie.Genres[0] != "sci-fi" || movie.Genres[1] != "drama" {
+		t.Errorf("ValidateMovie() genres = %v", movie.Genres)
+	}
+
+	for _, g := range [][]string{{"western"}, {"sci-fi", "Sci Fi"}} {
+		movie.Genres = g
+		v := validator.New()
+		if ValidateMovie(v, movie, genres); v.Valid() {
+			t.Errorf("ValidateMovie(%v) is valid, want error", g)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Sci-Fi":          "sci-fi",
+		"science fiction": "science-fiction",
+		"  ":              "",
+	}
+	for s, want := range tests {
+		if got := Slugify(s); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", s, got, want)
+		}
+	}
+	drama, anime := Slugify("ドラマ"), Slugify("アニメ")
+	if !GenreSlugRX.MatchString(drama) || drama == anime || drama != Slugify("ドラマ") {
+		t.Errorf("Slugify() of non-ASCII genres = %q, %q", drama, anime)
+	}
+}
+
+func TestMergeAliases(t *testing.T) {
+	target := &Genre{Slug: "sci-fi", Name: "Sci-Fi", Aliases: []string{"SF"}}
+	source := &Genre{Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{"science fiction", "sf"}}
+	aliases := mergeAliases(target, source)
+	if len(aliases) != 2 || aliases[0] != "SF" || aliases[1] != "science-fiction" {
+		t.Errorf("mergeAliases() = %v", aliases)
+	}
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Identity links a user to an account at an external OpenID Connect provider.
+type Identity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentitiesInterface interface {
+	InsertLogin(state, verifier, nonce string, ttl time.Duration) error
+	ConsumeLogin(state string) (verifier, nonce string, err error)
+	GetUser(issuer, subject string) (*User, error)
+	Insert(identity *Identity) error
+	GetAllForUser(userID int64) ([]*Identity, error)
+}
+
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// InsertLogin records a pending login so the callback can recover the PKCE
+// verifier and nonce. The state is stored hashed like any other token.
+func (m IdentityModel) InsertLogin(state, verifier, nonce string, ttl time.Duration) error {
+	query := `INSERT INTO oidc_logins (state_hash, verifier, nonce, expiry) VALUES ($1, $2, $3, $4)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, HashToken(state), verifier, nonce, time.Now().Add(ttl))
+	return err
+}
+
+// ConsumeLogin deletes the pending login for state, so each state can only be
+// redeemed once, along with any logins that have expired.
+func (m IdentityModel) ConsumeLogin(state string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := m.DB.ExecContext(ctx, `DELETE FROM oidc_logins WHERE expiry < NOW()`); err != nil {
+		return "", "", err
+	}
+	query := `DELETE FROM oidc_logins WHERE state_hash = $1 RETURNING verifier, nonce`
+	var verifier, nonce string
+	if err := m.DB.QueryRowContext(ctx, query, HashToken(state)).Scan(&verifier, &nonce); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", "", ErrRecordNotFound
+		default:
+			return "", "", err
+		}
+	}
+	return verifier, nonce, nil
+}
+
+func (m IdentityModel) GetUser(issuer, subject string) (*User, error) {
+	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	FROM users INNER JOIN user_identities ON users.id = user_identities.user_id
+	WHERE user_identities.issuer = $1 AND user_identities.subject = $2`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&user.ID, &user.CreatedAt, &user.Name, &user.Email, &user.Password.hash, &user.Activated, &user.Version,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func (m IdentityModel) Insert(identity *Identity) error {
+	query := `INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING RETURNING created_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID).Scan(&identity.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (m IdentityModel) GetAllForUser(userID int64) ([]*Identity, error) {
+	query := `SELECT issuer, subject, user_id, created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	identities := []*Identity{}
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
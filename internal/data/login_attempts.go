@@ -0,0 +1,141 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const maxLockout = 24 * time.Hour
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Locked reports whether the key is locked out at the given time and, if so,
+// for how much longer.
+func (a *LoginAttempt) Locked(now time.Time) (bool, time.Duration) {
+	if a.LockedUntil == nil || !a.LockedUntil.After(now) {
+		return false, 0
+	}
+	return true, a.LockedUntil.Sub(now)
+}
+
+// LockoutPolicy describes when a key is locked out. Once Threshold failures
+// have been recorded without a gap of Window between them, every further
+// failure locks the key for Duration, doubling with each failure up to a
+// maximum of one day. The time spent locked out doesn't count towards the
+// gap, so the counter only starts over once the key has gone Window without
+// a failure after its last lockout ended.
+type LockoutPolicy struct {
+	Threshold int
+	Duration  time.Duration
+	Window    time.Duration
+}
+
+func (p LockoutPolicy) lockout(failures int) time.Duration {
+	if p.Threshold < 1 || failures < p.Threshold {
+		return 0
+	}
+	d := p.Duration
+	for i := p.Threshold; i < failures && d < maxLockout; i++ {
+		d *= 2
+	}
+	if d > maxLockout {
+		d = maxLockout
+	}
+	return d
+}
+
+// record counts a failure at now against a, starting the count over if the
+// earlier failures are stale, and locks a out if the policy says so.
+func (p LockoutPolicy) record(a *LoginAttempt, now time.Time) {
+	staleBefore := now.Add(-p.Window)
+	if a.LastFailureAt.Before(staleBefore) && (a.LockedUntil == nil || a.LockedUntil.Before(staleBefore)) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	if lockout := p.lockout(a.Failures); lockout > 0 {
+		lockedUntil := now.Add(lockout)
+		a.LockedUntil = &lockedUntil
+	}
+}
+
+type LoginAttemptsInterface interface {
+	Get(key string) (*LoginAttempt, error)
+	RecordFailure(key string, policy LockoutPolicy) (*LoginAttempt, error)
+	Reset(key string) error
+	Prune(window time.Duration) (int64, error)
+}
+
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+func (m LoginAttemptModel) Get(key string) (*LoginAttempt, error) {
+	query := `SELECT failures, locked_until FROM login_attempts WHERE key = $1`
+	attempt := LoginAttempt{Key: key}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, key).Scan(&attempt.Failures, &attempt.LockedUntil); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return &attempt, nil
+		default:
+			return nil, err
+		}
+	}
+	return &attempt, nil
+}
+
+func (m LoginAttemptModel) RecordFailure(key string, policy LockoutPolicy) (*LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO login_attempts (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, key); err != nil {
+		return nil, err
+	}
+	query = `SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE`
+	attempt := LoginAttempt{Key: key}
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&attempt.Failures, &attempt.LastFailureAt, &attempt.LockedUntil); err != nil {
+		return nil, err
+	}
+	policy.record(&attempt, time.Now())
+	query = `UPDATE login_attempts SET failures = $2, last_failure_at = $3, locked_until = $4 WHERE key = $1`
+	if _, err := tx.ExecContext(ctx, query, key, attempt.Failures, attempt.LastFailureAt, attempt.LockedUntil); err != nil {
+		return nil, err
+	}
+	return &attempt, tx.Commit()
+}
+
+// Prune deletes the counters of keys that would start over on their next
+// failure, meaning the last failure and the end of any lockout are both more
+// than window ago.
+func (m LoginAttemptModel) Prune(window time.Duration) (int64, error) {
+	query := `DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, time.Now().Add(-window))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m LoginAttemptModel) Reset(key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, key)
+	return err
+}
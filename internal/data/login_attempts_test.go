@@ -0,0 +1,60 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyLockout(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 5, Duration: time.Minute, Window: 15 * time.Minute}
+	tests := []struct {
+		name     string
+		policy   LockoutPolicy
+		failures int
+		want     time.Duration
+	}{
+		{"below threshold", policy, 4, 0},
+		{"at threshold", policy, 5, time.Minute},
+		{"doubles", policy, 6, 2 * time.Minute},
+		{"doubles again", policy, 8, 8 * time.Minute},
+		{"capped", policy, 20, maxLockout},
+		{"far past cap", policy, 1000, maxLockout},
+		{"disabled", LockoutPolicy{Threshold: 0, Duration: time.Minute}, 50, 0},
+		{"duration above cap", LockoutPolicy{Threshold: 1, Duration: 48 * time.Hour}, 1, maxLockout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.lockout(tt.failures); got != tt.want {
+				t.Errorf("LockoutPolicy.lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutPolicyRecord(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 5, Duration: time.Minute, Window: 15 * time.Minute}
+	now := time.Now()
+	attempt := &LoginAttempt{}
+	for i := 0; i < 5; i++ {
+		policy.record(attempt, now)
+	}
+	// Failures aren't recorded while locked, so each one comes just after
+	// the previous lockout has run out.
+	want := time.Minute
+	for i := 0; i < 15; i++ {
+		if got := attempt.LockedUntil.Sub(now); got != want {
+			t.Fatalf("lockout %d = %v, want %v", i+1, got, want)
+		}
+		now = attempt.LockedUntil.Add(time.Second)
+		policy.record(attempt, now)
+		if want *= 2; want > maxLockout {
+			want = maxLockout
+		}
+	}
+
+	now = attempt.LockedUntil.Add(policy.Window + time.Second)
+	policy.record(attempt, now)
+	if attempt.Failures != 1 {
+		t.Errorf("failures after a quiet window = %d, want 1", attempt.Failures)
+	}
+}
@@ -9,21 +9,45 @@ var (
 	ErrRecordNotFound = errors.New("record not found")
 	ErrEditConflict   = errors.New("edit conflict")
 	ErrDuplicateEmail = errors.New("duplicate email")
+	ErrTokenReused    = errors.New("token reused")
+	ErrAdminLockout   = errors.New("admin lockout")
 )
 
 type Models struct {
-	Movies      MoviesInterface
-	Users       UsersInterface
-	Tokens      TokensInterface
-	Permissions PermissionsInterface
+	Movies        MoviesInterface
+	Users         UsersInterface
+	Tokens        TokensInterface
+	Permissions   PermissionsInterface
+	LoginAttempts LoginAttemptsInterface
+	TwoFactor     TwoFactorInterface
+	Audit         AuditInterface
+	Roles         RolesInterface
+	APIKeys       APIKeysInterface
+	Identities    IdentitiesInterface
+	Reviews       ReviewsInterface
+	Watchlist     WatchlistInterface
+	People        PeopleInterface
+	Genres        GenresInterface
+	Revisions     RevisionsInterface
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionsModel{DB: db},
+		Movies:        MovieModel{DB: db},
+		Users:         UserModel{DB: db},
+		Tokens:        TokenModel{DB: db},
+		Permissions:   PermissionsModel{DB: db},
+		LoginAttempts: LoginAttemptModel{DB: db},
+		TwoFactor:     TwoFactorModel{DB: db},
+		Audit:         AuditModel{DB: db},
+		Roles:         RoleModel{DB: db},
+		APIKeys:       APIKeyModel{DB: db},
+		Identities:    IdentityModel{DB: db},
+		Reviews:       ReviewModel{DB: db},
+		Watchlist:     WatchlistModel{DB: db},
+		People:        PeopleModel{DB: db},
+		Genres:        GenreModel{DB: db},
+		Revisions:     RevisionModel{DB: db},
 	}
 }
 
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/Sukrati192/greenlight/internal/validator"
 	"github.com/lib/pq"
@@ -19,9 +21,19 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
 	Version   int32     `json:"version"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	AverageRating float64 `json:"average_rating,omitempty"`
+	ReviewCount   int     `json:"review_count"`
+
+	Relevance float64 `json:"-"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// ValidateMovie checks a movie against the genre vocabulary. Genres given by
+// name or alias are replaced with their canonical slug; unknown genres are
+// rejected.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres Genres) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(movie.Year != 0, "year", "must be provided")
@@ -32,36 +44,113 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain atleast one genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+	for i, genre := range movie.Genres {
+		slug, ok := genres.Resolve(genre)
+		if !ok {
+			v.AddError("genres", "contains unknown genre "+genre)
+			continue
+		}
+		movie.Genres[i] = slug
+	}
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 }
 
 type MoviesInterface interface {
-	Insert(movie *Movie) error
+	Insert(movie *Movie, actorID int64) error
 	Get(id int64) (*Movie, error)
-	Update(movie *Movie) error
-	Delete(id int64) error
-	GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
+	Update(movie *Movie, action string, actorID int64) error
+	Delete(movie *Movie, actorID int64) error
+	GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error)
+	GetDeleted(filters Filters) ([]*Movie, Metadata, error)
+	Restore(id, actorID int64) error
+	Purge(retention time.Duration) (int64, error)
+}
+
+// MovieSearch holds the criteria movie listings can be narrowed by. Empty
+// fields match every movie.
+type MovieSearch struct {
+	Title      string
+	Genres     []string
+	Director   string
+	Actor      string
+	YearMin    int
+	YearMax    int
+	RuntimeMin int
+	RuntimeMax int
+}
+
+func ValidateMovieSearch(v *validator.Validator, search MovieSearch) {
+	v.Check(len(search.Title) <= 500, "title", "must not be more than 500 bytes long")
+	for key, year := range map[string]int{"year_min": search.YearMin, "year_max": search.YearMax} {
+		if year != 0 {
+			v.Check(year >= 1888, key, "must be greater than 1888")
+			v.Check(year <= time.Now().Year(), key, "must not be in the future")
+		}
+	}
+	v.Check(search.YearMax == 0 || search.YearMin <= search.YearMax, "year_min", "must not be greater than year_max")
+	v.Check(search.RuntimeMin >= 0, "runtime_min", "must be a positive integer")
+	v.Check(search.RuntimeMax >= 0, "runtime_max", "must be a positive integer")
+	v.Check(search.RuntimeMax == 0 || search.RuntimeMin <= search.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+}
+
+// titleQuery converts a title search into a tsquery. Quoted phrases must
+// match word for word and every other word matches as a prefix, so "star wa"
+// finds "Star Wars". Punctuation is dropped so users can't inject tsquery
+// operators.
+func titleQuery(search string) string {
+	var terms []string
+	for i, part := range strings.Split(search, `"`) {
+		words := strings.FieldsFunc(part, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		if i%2 == 1 {
+			terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			continue
+		}
+		for _, word := range words {
+			terms = append(terms, word+":*")
+		}
+	}
+	return strings.Join(terms, " & ")
 }
 
 type MovieModel struct {
 	DB *sql.DB
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert saves a new movie and its first revision, made by actorID.
+func (m MovieModel) Insert(movie *Movie, actorID int64) error {
 	query := `INSERT INTO movies (title, year, runtime, genres) VALUES ($1, $2, $3, $4) RETURNING id, created_at, version`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 	args := []interface{}{
 		movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres),
 	}
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version); err != nil {
+		return err
+	}
+	if err := recordRevision(ctx, tx, movie, RevisionCreate, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `SELECT id, created_at, title, year, runtime, genres, version FROM movies WHERE id=$1`
+	query := `SELECT id, created_at, title, year, runtime, genres, version,
+	COALESCE((SELECT round(avg(rating), 2) FROM reviews WHERE movie_id = movies.id), 0),
+	(SELECT count(*) FROM reviews WHERE movie_id = movies.id)
+	FROM movies WHERE id=$1 AND deleted_at IS NULL`
 	var movie Movie
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -73,6 +162,8 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
+		&movie.AverageRating,
+		&movie.ReviewCount,
 	); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -84,10 +175,17 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
-	query := `UPDATE movies SET title=$1, year=$2, runtime=$3, genres=$4, version = version + 1 WHERE id = $5 and version=$6 RETURNING version`
+// Update saves the movie and records a revision with the given action, made
+// by actorID, in the same transaction.
+func (m MovieModel) Update(movie *Movie, action string, actorID int64) error {
+	query := `UPDATE movies SET title=$1, year=$2, runtime=$3, genres=$4, version = version + 1 WHERE id = $5 and version=$6 AND deleted_at IS NULL RETURNING version`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 	args := []interface{}{
 		movie.Title,
 		movie.Year,
@@ -96,7 +194,7 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.ID,
 		movie.Version,
 	}
-	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version); err != nil {
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
@@ -104,17 +202,23 @@ func (m MovieModel) Update(movie *Movie) error {
 			return err
 		}
 	}
-	return nil
+	if err := recordRevision(ctx, tx, movie, action, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (m MovieModel) Delete(id int64) error {
-	if id < 1 {
-		return ErrRecordNotFound
-	}
-	query := `DELETE FROM movies where id=$1`
+// Delete moves the movie to the trash, recording it as it was just before.
+func (m MovieModel) Delete(movie *Movie, actorID int64) error {
+	query := `UPDATE movies SET deleted_at = NOW() WHERE id=$1 AND deleted_at IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, query, movie.ID)
 	if err != nil {
 		return err
 	}
@@ -125,16 +229,60 @@ func (m MovieModel) Delete(id int64) error {
 	if rowsAffected == 0 {
 		return ErrRecordNotFound
 	}
-	return nil
+	if err := recordRevision(ctx, tx, movie, RevisionDelete, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	query := fmt.Sprintf(`SELECT count(*) OVER(),id, created_at, title, year, runtime, genres, version FROM movies
-	WHERE (to_tsvector('simple',title) @@ plainto_tsquery('simple',$1) OR $1='') AND (genres @> $2 OR $2='{}')
-	ORDER by %s %s, id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+func (m MovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	// Titles match the prefix and phrase query in $1, or by trigram
+	// similarity to the raw search in $5 to tolerate typos.
+	where := `deleted_at IS NULL
+	AND (($1 = '' AND $5 = '') OR ($1 <> '' AND to_tsvector('simple', title) @@ to_tsquery('simple', $1)) OR $5 <% title)
+	AND (genres @> $2 OR $2='{}')
+	AND ($3 = '' OR EXISTS (SELECT 1 FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.movie_id = movies.id AND movie_credits.role = 'director'
+		AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $3)))
+	AND ($4 = '' OR EXISTS (SELECT 1 FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.movie_id = movies.id AND movie_credits.role = 'actor'
+		AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $4)))
+	AND ($6 = 0 OR year >= $6) AND ($7 = 0 OR year <= $7)
+	AND ($8 = 0 OR runtime >= $8) AND ($9 = 0 OR runtime <= $9)`
+	relevance := `(CASE WHEN $1 = '' THEN 0 ELSE ts_rank(to_tsvector('simple', title), to_tsquery('simple', $1)) END
+	+ word_similarity($5, title))::real`
+	// A window count would only see the rows past the cursor, so cursor pages
+	// count the matching movies separately.
+	total := "count(*) OVER()"
+	switch {
+	case filters.SkipTotal:
+		total = "0"
+	case filters.Cursor != "":
+		total = "(SELECT count(*) FROM movies WHERE " + where + ")"
+	}
+	sortExpr := filters.sortColumn()
+	switch sortExpr {
+	case "rating":
+		sortExpr = "COALESCE(ratings.average, 0)"
+	case "relevance":
+		sortExpr = relevance
+	}
+	keyset, keysetArgs := filters.keyset(sortExpr, "id", 12)
+	sortDirection, idDirection := filters.keysetOrder()
+	query := fmt.Sprintf(`SELECT %s, id, created_at, title, year, runtime, genres, version,
+	COALESCE(ratings.average, 0) AS rating, COALESCE(ratings.reviews, 0), %s AS relevance FROM movies
+	LEFT JOIN (SELECT movie_id, round(avg(rating), 2) AS average, count(*) AS reviews FROM reviews GROUP BY movie_id) ratings
+	ON ratings.movie_id = movies.id
+	WHERE %s AND %s
+	ORDER by %s %s, id %s LIMIT $10 OFFSET $11`, total, relevance, where, keyset, filters.sortColumn(), sortDirection, idDirection)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []interface{}{
+		titleQuery(search.Title), pq.Array(search.Genres), search.Director, search.Actor, search.Title,
+		search.YearMin, search.YearMax, search.RuntimeMin, search.RuntimeMax,
+		filters.keysetLimit(), filters.keysetOffset(),
+	}
+	args = append(args, keysetArgs...)
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -144,7 +292,58 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	movies := []*Movie{}
 	for rows.Next() {
 		var movie Movie
-		if err := rows.Scan(&totalRecords, &movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version); err != nil {
+		if err := rows.Scan(&totalRecords, &movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version,
+			&movie.AverageRating, &movie.ReviewCount, &movie.Relevance); err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	movies, metadata := paginate(movies, totalRecords, filters, movieSortKey(filters.sortColumn()))
+	return movies, metadata, nil
+}
+
+// movieSortKey returns a function giving the value of column and the id of a
+// movie, for building cursors.
+func movieSortKey(column string) func(*Movie) (interface{}, int64) {
+	return func(movie *Movie) (interface{}, int64) {
+		switch column {
+		case "title":
+			return movie.Title, movie.ID
+		case "year":
+			return movie.Year, movie.ID
+		case "runtime":
+			return int32(movie.Runtime), movie.ID
+		case "rating":
+			return movie.AverageRating, movie.ID
+		case "relevance":
+			return movie.Relevance, movie.ID
+		default:
+			return movie.ID, movie.ID
+		}
+	}
+}
+
+// GetDeleted returns the soft-deleted movies that are waiting to be purged.
+func (m MovieModel) GetDeleted(filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, deleted_at
+	FROM movies WHERE deleted_at IS NOT NULL
+	ORDER by %s %s, id ASC LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		if err := rows.Scan(&totalRecords, &movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version,
+			&movie.DeletedAt); err != nil {
 			return nil, Metadata{}, err
 		}
 		movies = append(movies, &movie)
@@ -156,11 +355,56 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, metadata, nil
 }
 
+// Restore takes the movie back out of the trash and records an undelete
+// revision made by actorID.
+func (m MovieModel) Restore(id, actorID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `UPDATE movies SET deleted_at = NULL, version = version + 1 WHERE id=$1 AND deleted_at IS NOT NULL
+	RETURNING id, title, year, runtime, genres, version`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var movie Movie
+	if err := tx.QueryRowContext(ctx, query, id).Scan(
+		&movie.ID, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.Version,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	if err := recordRevision(ctx, tx, &movie, RevisionUndelete, actorID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge permanently deletes movies that have been in the trash for longer
+// than retention, returning how many were removed.
+func (m MovieModel) Purge(retention time.Duration) (int64, error) {
+	query := `DELETE FROM movies WHERE deleted_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 type MockMovieModel struct {
 	DB *sql.DB
 }
 
-func (m MockMovieModel) Insert(movie *Movie) error {
+func (m MockMovieModel) Insert(movie *Movie, actorID int64) error {
 	return nil
 }
 
@@ -168,14 +412,26 @@ func (m MockMovieModel) Get(id int64) (*Movie, error) {
 	return nil, nil
 }
 
-func (m MockMovieModel) Update(movie *Movie) error {
+func (m MockMovieModel) Update(movie *Movie, action string, actorID int64) error {
 	return nil
 }
 
-func (m MockMovieModel) Delete(id int64) error {
+func (m MockMovieModel) Delete(movie *Movie, actorID int64) error {
 	return nil
 }
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+func (m MockMovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+
+func (m MockMovieModel) GetDeleted(filters Filters) ([]*Movie, Metadata, error) {
 	return nil, Metadata{}, nil
 }
+
+func (m MockMovieModel) Restore(id, actorID int64) error {
+	return nil
+}
+
+func (m MockMovieModel) Purge(retention time.Duration) (int64, error) {
+	return 0, nil
+}
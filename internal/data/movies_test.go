@@ -0,0 +1,19 @@
+package data
+
+import "testing"
+
+func TestTitleQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"star wa":                "star:* & wa:*",
+		`"the empire" strikes`:   "(the <-> empire) & strikes:*",
+		"alien & !predator | x:": "alien:* & predator:* & x:*",
+		`"unterminated phrase`:   "(unterminated <-> phrase)",
+		"!!!":                    "",
+	}
+	for search, want := range tests {
+		if got := titleQuery(search); got != want {
+			t.Errorf("titleQuery(%q) = %q, want %q", search, got, want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+
+	CreditRoles = []string{"director", "actor", "writer"}
+)
+
+const ErrPsqlDuplicateCredit = `pq: duplicate key value violates unique constraint "movie_credits_unique_key"`
+
+type Person struct {
+	ID          int64             `json:"id"`
+	CreatedAt   time.Time         `json:"-"`
+	Name        string            `json:"name"`
+	BirthYear   int32             `json:"birth_year,omitempty"`
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	Version     int32             `json:"version"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+	if person.BirthYear != 0 {
+		v.Check(person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "must not be in the future")
+	}
+	v.Check(len(person.ExternalIDs) <= 10, "external_ids", "must not contain more than 10 entries")
+	for source, id := range person.ExternalIDs {
+		v.Check(source != "" && len(source) <= 50, "external_ids", "must have sources between 1 and 50 bytes long")
+		v.Check(id != "" && len(id) <= 100, "external_ids", "must have IDs between 1 and 100 bytes long")
+	}
+}
+
+// Credit is a person's part in a movie. Name is the person's name and Title
+// and Year describe the movie, so a credit can be shown from either side.
+type Credit struct {
+	ID        int64  `json:"id"`
+	MovieID   int64  `json:"movie_id"`
+	Title     string `json:"title,omitempty"`
+	Year      int32  `json:"year,omitempty"`
+	PersonID  int64  `json:"person_id"`
+	Name      string `json:"name,omitempty"`
+	Role      string `json:"role"`
+	Character string `json:"character,omitempty"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "must be one of director, actor or writer")
+	v.Check(credit.Role == "actor" || credit.Character == "", "character", "must only be provided for actors")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+}
+
+type PeopleInterface interface {
+	Insert(person *Person) error
+	Get(id int64) (*Person, error)
+	Update(person *Person) error
+	Delete(id int64) error
+	GetAll(name string, filters Filters) ([]*Person, Metadata, error)
+	InsertCredit(credit *Credit) error
+	DeleteCredit(movieID, id int64) error
+	GetCreditsForMovie(movieID int64) ([]*Credit, error)
+	GetCreditsForPerson(personID int64) ([]*Credit, error)
+}
+
+type PeopleModel struct {
+	DB *sql.DB
+}
+
+func (m PeopleModel) Insert(person *Person) error {
+	externalIDs, err := json.Marshal(person.externalIDs())
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO people (name, birth_year, external_ids) VALUES ($1, NULLIF($2, 0), $3)
+	RETURNING id, created_at, version`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, person.Name, person.BirthYear, externalIDs).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PeopleModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id, created_at, name, COALESCE(birth_year, 0), external_ids, version FROM people WHERE id = $1`
+	var person Person
+	var externalIDs []byte
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID, &person.CreatedAt, &person.Name, &person.BirthYear, &externalIDs, &person.Version,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(externalIDs, &person.ExternalIDs); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (m PeopleModel) Update(person *Person) error {
+	externalIDs, err := json.Marshal(person.externalIDs())
+	if err != nil {
+		return err
+	}
+	query := `UPDATE people SET name = $1, birth_year = NULLIF($2, 0), external_ids = $3, version = version + 1
+	WHERE id = $4 AND version = $5 RETURNING version`
+	args := []interface{}{person.Name, person.BirthYear, externalIDs, person.ID, person.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m PeopleModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM people WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m PeopleModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, name, COALESCE(birth_year, 0), external_ids, version FROM people
+	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	people := []*Person{}
+	for rows.Next() {
+		var person Person
+		var externalIDs []byte
+		if err := rows.Scan(&totalRecords, &person.ID, &person.CreatedAt, &person.Name, &person.BirthYear, &externalIDs, &person.Version); err != nil {
+			return nil, Metadata{}, err
+		}
+		if err := json.Unmarshal(externalIDs, &person.ExternalIDs); err != nil {
+			return nil, Metadata{}, err
+		}
+		people = append(people, &person)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return people, metadata, nil
+}
+
+func (m PeopleModel) InsertCredit(credit *Credit) error {
+	query := `INSERT INTO movie_credits (movie_id, person_id, role, character) VALUES ($1, $2, $3, $4)
+	RETURNING id, (SELECT name FROM people WHERE id = $2)`
+	args := []interface{}{credit.MovieID, credit.PersonID, credit.Role, credit.Character}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.Name); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateCredit:
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m PeopleModel) DeleteCredit(movieID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM movie_credits WHERE id = $1 AND movie_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m PeopleModel) GetCreditsForMovie(movieID int64) ([]*Credit, error) {
+	query := `SELECT movie_credits.id, movie_credits.movie_id, '', 0, movie_credits.person_id, people.name,
+	movie_credits.role, movie_credits.character
+	FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.movie_id = $1
+	ORDER BY array_position(ARRAY['director', 'writer', 'actor'], movie_credits.role), movie_credits.id`
+	return m.queryCredits(query, movieID)
+}
+
+func (m PeopleModel) GetCreditsForPerson(personID int64) ([]*Credit, error) {
+	query := `SELECT movie_credits.id, movie_credits.movie_id, movies.title, movies.year, movie_credits.person_id, '',
+	movie_credits.role, movie_credits.character
+	FROM movie_credits INNER JOIN movies ON movies.id = movie_credits.movie_id
+	WHERE movie_credits.person_id = $1 AND movies.deleted_at IS NULL
+	ORDER BY movies.year DESC, movie_credits.id`
+	return m.queryCredits(query, personID)
+}
+
+func (m PeopleModel) queryCredits(query string, args ...interface{}) ([]*Credit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	credits := []*Credit{}
+	for rows.Next() {
+		var credit Credit
+		if err := rows.Scan(&credit.ID, &credit.MovieID, &credit.Title, &credit.Year, &credit.PersonID, &credit.Name,
+			&credit.Role, &credit.Character); err != nil {
+			return nil, err
+		}
+		credits = append(credits, &credit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
+
+func (p *Person) externalIDs() map[string]string {
+	if p.ExternalIDs == nil {
+		return map[string]string{}
+	}
+	return p.ExternalIDs
+}
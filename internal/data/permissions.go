@@ -3,38 +3,82 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/Sukrati192/greenlight/internal/validator"
 	"github.com/lib/pq"
 )
 
 type Permissions []string
 
+// Include reports whether code is granted by any of the permissions. A
+// permission ending in "*" grants every code with the same prefix, so
+// "movies:*" grants "movies:write" and "*" grants everything.
 func (p Permissions) Include(code string) bool {
 	for _, permission := range p {
 		if code == permission {
 			return true
 		}
+		if prefix, ok := strings.CutSuffix(permission, "*"); ok && strings.HasPrefix(code, prefix) {
+			return true
+		}
 	}
 	return false
 }
 
+// ValidatePermissionCode checks that code is either a known permission or a
+// wildcard covering at least one known permission.
+func ValidatePermissionCode(v *validator.Validator, key, code string, known Permissions) {
+	if prefix, ok := strings.CutSuffix(code, "*"); ok {
+		valid := prefix == "" || strings.HasSuffix(prefix, ":")
+		if valid && prefix != "" {
+			valid = false
+			for _, k := range known {
+				if strings.HasPrefix(k, prefix) {
+					valid = true
+					break
+				}
+			}
+		}
+		v.Check(valid, key, "contains invalid wildcard "+code)
+		return
+	}
+	v.Check(validator.In(code, known...), key, "contains unknown permission "+code)
+}
+
+const (
+	userPermissionsQuery = `SELECT permissions.code FROM permissions
+	INNER JOIN users_permissions ON users_permissions.permission_id=permissions.id WHERE users_permissions.user_id=$1
+	UNION
+	SELECT roles_permissions.code FROM roles_permissions
+	INNER JOIN users_roles ON users_roles.role_id=roles_permissions.role_id WHERE users_roles.user_id=$1`
+	addPermissionsQuery = `INSERT INTO users_permissions SELECT $1, permissions.id FROM permissions
+	WHERE permissions.code=ANY($2) ON CONFLICT DO NOTHING`
+	removePermissionsQuery = `DELETE FROM users_permissions USING permissions
+	WHERE users_permissions.permission_id=permissions.id AND users_permissions.user_id=$1 AND permissions.code=ANY($2)`
+)
+
 type PermissionsModel struct {
 	DB *sql.DB
 }
 
 type PermissionsInterface interface {
+	GetAll() (Permissions, error)
 	GetAllForUser(userID int64) (Permissions, error)
 	AddForUser(userID int64, codes ...string) error
+	RemoveForUser(userID int64, codes ...string) error
+	GrantForUser(userID int64, codes []string, entry *AuditEntry) error
+	RevokeForUser(userID int64, codes []string, entry *AuditEntry) error
+	GetUsersForPermission(code string, filters Filters) ([]*User, Metadata, error)
 }
 
-func (m PermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
-	query := `SELECT permissions.code FROM permissions
-	INNER JOIN users_permissions ON users_permissions.permission_id=permissions.id
-	INNER JOIN users ON users_permissions.user_id=users.id WHERE users.id=$1`
+func (m PermissionsModel) GetAll() (Permissions, error) {
+	query := `SELECT code FROM permissions ORDER BY code`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	rows, err := m.DB.QueryContext(ctx, query, userID)
+	rows, err := m.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +97,112 @@ func (m PermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
 	return permissions, nil
 }
 
+func (m PermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, userPermissionsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPermissions(rows)
+}
+
+func scanPermissions(rows *sql.Rows) (Permissions, error) {
+	defer rows.Close()
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// checkAdminLockout returns ErrAdminLockout if the change made in tx was made
+// by the user to themselves and has left them without users:admin, whether
+// it was granted directly, through a role or by a wildcard.
+func checkAdminLockout(ctx context.Context, tx *sql.Tx, userID int64, entry *AuditEntry) error {
+	if entry.ActorID != userID {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, userPermissionsQuery, userID)
+	if err != nil {
+		return err
+	}
+	permissions, err := scanPermissions(rows)
+	if err != nil {
+		return err
+	}
+	if !permissions.Include("users:admin") {
+		return ErrAdminLockout
+	}
+	return nil
+}
+
 func (m PermissionsModel) AddForUser(userID int64, codes ...string) error {
-	query := `INSERT INTO users_permissions SELECT $1, permissions.id FROM permissions
-	WHERE permissions.code=ANY($2)`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	_, err := m.DB.ExecContext(ctx, addPermissionsQuery, userID, pq.Array(codes))
+	return err
+}
+
+func (m PermissionsModel) RemoveForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, removePermissionsQuery, userID, pq.Array(codes))
 	return err
 }
+
+// GrantForUser adds permissions to a user and records entry in the audit log
+// in the same transaction.
+func (m PermissionsModel) GrantForUser(userID int64, codes []string, entry *AuditEntry) error {
+	return m.changeForUser(addPermissionsQuery, userID, codes, entry)
+}
+
+// RevokeForUser removes permissions from a user and records entry in the
+// audit log in the same transaction.
+func (m PermissionsModel) RevokeForUser(userID int64, codes []string, entry *AuditEntry) error {
+	return m.changeForUser(removePermissionsQuery, userID, codes, entry)
+}
+
+func (m PermissionsModel) changeForUser(query string, userID int64, codes []string, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(codes)); err != nil {
+		return err
+	}
+	if err := checkAdminLockout(ctx, tx, userID, entry); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m PermissionsModel) GetUsersForPermission(code string, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, name, email, activated, version FROM users
+	WHERE EXISTS (SELECT 1 FROM users_permissions INNER JOIN permissions ON users_permissions.permission_id=permissions.id
+		WHERE users_permissions.user_id=users.id AND permissions.code=$1)
+	OR EXISTS (SELECT 1 FROM users_roles INNER JOIN roles_permissions ON roles_permissions.role_id=users_roles.role_id
+		WHERE users_roles.user_id=users.id
+		AND (roles_permissions.code=$1 OR $1 LIKE replace(roles_permissions.code, '*', '%%') AND roles_permissions.code LIKE '%%*'))
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, code, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanUsers(rows, filters)
+}
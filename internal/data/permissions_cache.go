@@ -0,0 +1,170 @@
+package data
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxPermissionsCacheEntries = 10_000
+
+type permissionsCacheEntry struct {
+	permissions Permissions
+	expiry      time.Time
+}
+
+// PermissionsCache holds each user's effective permissions for a bounded
+// TTL. Grants changed through the wrapped models invalidate the affected
+// entries immediately; changes made by other instances become visible once
+// the TTL runs out.
+type PermissionsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[int64]permissionsCacheEntry
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+type PermissionsCacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+func NewPermissionsCache(ttl time.Duration) *PermissionsCache {
+	return &PermissionsCache{
+		ttl:     ttl,
+		entries: make(map[int64]permissionsCacheEntry),
+	}
+}
+
+// Wrap returns a copy of models whose Permissions and Roles go through the
+// cache.
+func (c *PermissionsCache) Wrap(models Models) Models {
+	models.Permissions = cachedPermissionsModel{PermissionsInterface: models.Permissions, cache: c}
+	models.Roles = cachedRoleModel{RolesInterface: models.Roles, cache: c}
+	return models
+}
+
+func (c *PermissionsCache) Stats() PermissionsCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return PermissionsCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: entries}
+}
+
+func (c *PermissionsCache) get(userID int64) (Permissions, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok || time.Now().After(entry.expiry) {
+		delete(c.entries, userID)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return entry.permissions, true
+}
+
+func (c *PermissionsCache) set(userID int64, permissions Permissions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxPermissionsCacheEntries {
+		for id, entry := range c.entries {
+			if now.After(entry.expiry) {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxPermissionsCacheEntries {
+			c.entries = make(map[int64]permissionsCacheEntry)
+		}
+	}
+	c.entries[userID] = permissionsCacheEntry{permissions: permissions, expiry: now.Add(c.ttl)}
+}
+
+func (c *PermissionsCache) invalidate(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+func (c *PermissionsCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[int64]permissionsCacheEntry)
+}
+
+type cachedPermissionsModel struct {
+	PermissionsInterface
+	cache *PermissionsCache
+}
+
+func (m cachedPermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
+	if permissions, ok := m.cache.get(userID); ok {
+		return permissions, nil
+	}
+	permissions, err := m.PermissionsInterface.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.set(userID, permissions)
+	return permissions, nil
+}
+
+func (m cachedPermissionsModel) AddForUser(userID int64, codes ...string) error {
+	defer m.cache.invalidate(userID)
+	return m.PermissionsInterface.AddForUser(userID, codes...)
+}
+
+func (m cachedPermissionsModel) RemoveForUser(userID int64, codes ...string) error {
+	defer m.cache.invalidate(userID)
+	return m.PermissionsInterface.RemoveForUser(userID, codes...)
+}
+
+func (m cachedPermissionsModel) GrantForUser(userID int64, codes []string, entry *AuditEntry) error {
+	defer m.cache.invalidate(userID)
+	return m.PermissionsInterface.GrantForUser(userID, codes, entry)
+}
+
+func (m cachedPermissionsModel) RevokeForUser(userID int64, codes []string, entry *AuditEntry) error {
+	defer m.cache.invalidate(userID)
+	return m.PermissionsInterface.RevokeForUser(userID, codes, entry)
+}
+
+// cachedRoleModel invalidates cached permissions whenever a change to roles
+// could alter a user's effective grants.
+type cachedRoleModel struct {
+	RolesInterface
+	cache *PermissionsCache
+}
+
+func (m cachedRoleModel) Update(role *Role, entry *AuditEntry) error {
+	defer m.cache.invalidateAll()
+	return m.RolesInterface.Update(role, entry)
+}
+
+func (m cachedRoleModel) Delete(id int64, entry *AuditEntry) error {
+	defer m.cache.invalidateAll()
+	return m.RolesInterface.Delete(id, entry)
+}
+
+func (m cachedRoleModel) AddForUser(userID int64, names ...string) error {
+	defer m.cache.invalidate(userID)
+	return m.RolesInterface.AddForUser(userID, names...)
+}
+
+func (m cachedRoleModel) RemoveForUser(userID int64, names ...string) error {
+	defer m.cache.invalidate(userID)
+	return m.RolesInterface.RemoveForUser(userID, names...)
+}
+
+func (m cachedRoleModel) AssignForUser(userID int64, names []string, entry *AuditEntry) error {
+	defer m.cache.invalidate(userID)
+	return m.RolesInterface.AssignForUser(userID, names, entry)
+}
+
+func (m cachedRoleModel) UnassignForUser(userID int64, names []string, entry *AuditEntry) error {
+	defer m.cache.invalidate(userID)
+	return m.RolesInterface.UnassignForUser(userID, names, entry)
+}
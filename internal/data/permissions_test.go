@@ -0,0 +1,91 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+func TestPermissionsInclude(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions Permissions
+		code        string
+		want        bool
+	}{
+		{"exact match", Permissions{"movies:read"}, "movies:read", true},
+		{"no match", Permissions{"movies:read"}, "movies:write", false},
+		{"namespace wildcard", Permissions{"movies:*"}, "movies:write", true},
+		{"wildcard other namespace", Permissions{"movies:*"}, "users:admin", false},
+		{"global wildcard", Permissions{"*"}, "users:admin", true},
+		{"empty", nil, "movies:read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.permissions.Include(tt.code); got != tt.want {
+				t.Errorf("Permissions.Include(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePermissionCode(t *testing.T) {
+	known := Permissions{"movies:read", "movies:write", "users:admin"}
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"movies:read", true},
+		{"movies:delete", false},
+		{"movies:*", true},
+		{"*", true},
+		{"reviews:*", false},
+		{"mov*", false},
+	}
+	for _, tt := range tests {
+		v := validator.New()
+		if ValidatePermissionCode(v, "permissions", tt.code, known); v.Valid() != tt.want {
+			t.Errorf("ValidatePermissionCode(%q) valid = %v, want %v", tt.code, v.Valid(), tt.want)
+		}
+	}
+}
+
+type countingPermissionsModel struct {
+	PermissionsInterface
+	calls int
+}
+
+func (m *countingPermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
+	m.calls++
+	return Permissions{"movies:read"}, nil
+}
+
+func (m *countingPermissionsModel) AddForUser(userID int64, codes ...string) error {
+	return nil
+}
+
+func TestPermissionsCache(t *testing.T) {
+	model := &countingPermissionsModel{}
+	cache := NewPermissionsCache(time.Minute)
+	models := cache.Wrap(Models{Permissions: model})
+
+	for i := 0; i < 3; i++ {
+		if _, err := models.Permissions.GetAllForUser(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if model.calls != 1 {
+		t.Errorf("GetAllForUser() hit the database %d times, want 1", model.calls)
+	}
+	if err := models.Permissions.AddForUser(1, "movies:write"); err != nil {
+		t.Fatal(err)
+	}
+	models.Permissions.GetAllForUser(1)
+	if model.calls != 2 {
+		t.Errorf("GetAllForUser() after AddForUser hit the database %d times, want 2", model.calls)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 2 hits and 2 misses", stats)
+	}
+}
@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+var ErrDuplicateReview = errors.New("duplicate review")
+
+const ErrPsqlDuplicateReview = `pq: duplicate key value violates unique constraint "reviews_movie_id_user_id_key"`
+
+type Review struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"body"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1 && review.Rating <= 10, "rating", "must be between 1 and 10")
+	v.Check(review.Body != "", "body", "must be provided")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
+
+type ReviewsInterface interface {
+	Insert(review *Review) error
+	Get(movieID, id int64) (*Review, error)
+	Update(review *Review) error
+	Delete(id int64) error
+	GetAllForMovie(movieID int64, filters Filters) ([]*Review, Metadata, error)
+	GetAllForUser(userID int64) ([]*Review, error)
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+func (m ReviewModel) Insert(review *Review) error {
+	query := `INSERT INTO reviews (movie_id, user_id, rating, body) VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at, version`
+	args := []interface{}{review.MovieID, review.UserID, review.Rating, review.Body}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateReview:
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ReviewModel) Get(movieID, id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id, created_at, updated_at, movie_id, user_id, rating, body, version FROM reviews
+	WHERE id = $1 AND movie_id = $2`
+	var review Review
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.MovieID, &review.UserID, &review.Rating, &review.Body, &review.Version,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &review, nil
+}
+
+func (m ReviewModel) Update(review *Review) error {
+	query := `UPDATE reviews SET rating = $1, body = $2, updated_at = NOW(), version = version + 1
+	WHERE id = $3 AND version = $4 RETURNING updated_at, version`
+	args := []interface{}{review.Rating, review.Body, review.ID, review.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.UpdatedAt, &review.Version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ReviewModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM reviews WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m ReviewModel) GetAllForMovie(movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, updated_at, movie_id, user_id, rating, body, version
+	FROM reviews WHERE movie_id = $1
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		if err := rows.Scan(&totalRecords, &review.ID, &review.CreatedAt, &review.UpdatedAt, &review.MovieID,
+			&review.UserID, &review.Rating, &review.Body, &review.Version); err != nil {
+			return nil, Metadata{}, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
+
+func (m ReviewModel) GetAllForUser(userID int64) ([]*Review, error) {
+	query := `SELECT id, created_at, updated_at, movie_id, user_id, rating, body, version FROM reviews
+	WHERE user_id = $1 ORDER BY id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		if err := rows.Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.MovieID,
+			&review.UserID, &review.Rating, &review.Body, &review.Version); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionDelete   = "delete"
+	RevisionRestore  = "restore"
+	RevisionUndelete = "undelete"
+)
+
+// MovieSnapshot is the state of a movie's editable fields at one revision.
+type MovieSnapshot struct {
+	Title   string   `json:"title"`
+	Year    int32    `json:"year"`
+	Runtime Runtime  `json:"runtime"`
+	Genres  []string `json:"genres"`
+	Version int32    `json:"version"`
+}
+
+func SnapshotMovie(movie *Movie) MovieSnapshot {
+	return MovieSnapshot{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+		Version: movie.Version,
+	}
+}
+
+// Revision is a movie as it was after an insert, update or restore, or just
+// before a delete. Revisions are kept after the movie is deleted, so there is
+// no foreign key on movie_id or actor_id.
+type Revision struct {
+	ID        int64         `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	MovieID   int64         `json:"movie_id"`
+	Action    string        `json:"action"`
+	ActorID   int64         `json:"actor_id"`
+	Snapshot  MovieSnapshot `json:"movie"`
+}
+
+type Change struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Diff lists the fields that differ between two snapshots. The version is
+// left out since it changes on every revision.
+func Diff(from, to MovieSnapshot) []Change {
+	changes := []Change{}
+	if from.Title != to.Title {
+		changes = append(changes, Change{"title", from.Title, to.Title})
+	}
+	if from.Year != to.Year {
+		changes = append(changes, Change{"year", from.Year, to.Year})
+	}
+	if from.Runtime != to.Runtime {
+		changes = append(changes, Change{"runtime", from.Runtime, to.Runtime})
+	}
+	if !reflect.DeepEqual(from.Genres, to.Genres) {
+		changes = append(changes, Change{"genres", from.Genres, to.Genres})
+	}
+	return changes
+}
+
+type RevisionsInterface interface {
+	Get(movieID, id int64) (*Revision, error)
+	GetPrevious(movieID, id int64) (*Revision, error)
+	GetAllForMovie(movieID int64, filters Filters) ([]*Revision, Metadata, error)
+	GetAllForActor(actorID int64) ([]*Revision, error)
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+// insertRevision writes revision as part of tx, so that a movie change is
+// only committed together with its revision.
+func insertRevision(ctx context.Context, tx *sql.Tx, revision *Revision) error {
+	snapshot, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO movie_revisions (movie_id, action, actor_id, snapshot) VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+	args := []interface{}{revision.MovieID, revision.Action, revision.ActorID, snapshot}
+	return tx.QueryRowContext(ctx, query, args...).Scan(&revision.ID, &revision.CreatedAt)
+}
+
+// recordRevision is a shorthand for inserting a revision of movie.
+func recordRevision(ctx context.Context, tx *sql.Tx, movie *Movie, action string, actorID int64) error {
+	return insertRevision(ctx, tx, &Revision{
+		MovieID:  movie.ID,
+		Action:   action,
+		ActorID:  actorID,
+		Snapshot: SnapshotMovie(movie),
+	})
+}
+
+func (m RevisionModel) Get(movieID, id int64) (*Revision, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id, created_at, movie_id, action, actor_id, snapshot FROM movie_revisions
+	WHERE id = $1 AND movie_id = $2`
+	return m.get(query, id, movieID)
+}
+
+// GetPrevious returns the revision of the movie that came before id.
+func (m RevisionModel) GetPrevious(movieID, id int64) (*Revision, error) {
+	query := `SELECT id, created_at, movie_id, action, actor_id, snapshot FROM movie_revisions
+	WHERE id < $1 AND movie_id = $2 ORDER BY id DESC LIMIT 1`
+	return m.get(query, id, movieID)
+}
+
+func (m RevisionModel) get(query string, args ...interface{}) (*Revision, error) {
+	var revision Revision
+	var snapshot []byte
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&revision.ID, &revision.CreatedAt, &revision.MovieID, &revision.Action, &revision.ActorID, &snapshot,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (m RevisionModel) GetAllForMovie(movieID int64, filters Filters) ([]*Revision, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, movie_id, action, actor_id, snapshot
+	FROM movie_revisions WHERE movie_id = $1
+	ORDER BY %s %s LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	revisions := []*Revision{}
+	for rows.Next() {
+		var revision Revision
+		var snapshot []byte
+		if err := rows.Scan(&totalRecords, &revision.ID, &revision.CreatedAt, &revision.MovieID, &revision.Action,
+			&revision.ActorID, &snapshot); err != nil {
+			return nil, Metadata{}, err
+		}
+		if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+// GetAllForActor returns every revision made by the user, oldest first.
+func (m RevisionModel) GetAllForActor(actorID int64) ([]*Revision, error) {
+	query := `SELECT id, created_at, movie_id, action, actor_id, snapshot FROM movie_revisions
+	WHERE actor_id = $1 ORDER BY id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, actorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []*Revision{}
+	for rows.Next() {
+		var revision Revision
+		var snapshot []byte
+		if err := rows.Scan(&revision.ID, &revision.CreatedAt, &revision.MovieID, &revision.Action,
+			&revision.ActorID, &snapshot); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
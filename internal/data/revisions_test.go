@@ -0,0 +1,18 @@
+package data
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	from := MovieSnapshot{Title: "Alien", Year: 1979, Runtime: 117, Genres: []string{"sci-fi"}, Version: 1}
+	to := MovieSnapshot{Title: "Aliens", Year: 1979, Runtime: 117, Genres: []string{"sci-fi", "action"}, Version: 2}
+	changes := Diff(from, to)
+	if len(changes) != 2 || changes[0].Field != "title" || changes[1].Field != "genres" {
+		t.Fatalf("Diff() = %+v", changes)
+	}
+	if changes[0].From != "Alien" || changes[0].To != "Aliens" {
+		t.Errorf("Diff() title change = %+v", changes[0])
+	}
+	if changes := Diff(from, from); len(changes) != 0 {
+		t.Errorf("Diff() of identical snapshots = %+v", changes)
+	}
+}
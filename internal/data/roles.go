@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateRole = errors.New("duplicate role")
+
+	RoleNameRX = regexp.MustCompile("^[a-z][a-z0-9_-]*$")
+)
+
+const ErrPsqlDuplicateRole = `pq: duplicate key value violates unique constraint "roles_name_key"`
+
+type Role struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"-"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Permissions Permissions `json:"permissions"`
+	Version     int32       `json:"version"`
+}
+
+func ValidateRole(v *validator.Validator, role *Role, known Permissions) {
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(role.Name, RoleNameRX), "name", "must contain only lowercase letters, digits, - and _")
+	v.Check(len(role.Description) <= 500, "description", "must not be more than 500 bytes long")
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(validator.Unique(role.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range role.Permissions {
+		ValidatePermissionCode(v, "permissions", code, known)
+	}
+}
+
+type RolesInterface interface {
+	Insert(role *Role, entry *AuditEntry) error
+	Get(id int64) (*Role, error)
+	GetAll() ([]*Role, error)
+	Update(role *Role, entry *AuditEntry) error
+	Delete(id int64, entry *AuditEntry) error
+	GetAllForUser(userID int64) ([]*Role, error)
+	AddForUser(userID int64, names ...string) error
+	RemoveForUser(userID int64, names ...string) error
+	AssignForUser(userID int64, names []string, entry *AuditEntry) error
+	UnassignForUser(userID int64, names []string, entry *AuditEntry) error
+}
+
+const (
+	addRolesQuery    = `INSERT INTO users_roles SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2) ON CONFLICT DO NOTHING`
+	removeRolesQuery = `DELETE FROM users_roles USING roles
+	WHERE users_roles.role_id = roles.id AND users_roles.user_id = $1 AND roles.name = ANY($2)`
+)
+
+type RoleModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a role and records entry in the audit log in the same
+// transaction.
+func (m RoleModel) Insert(role *Role, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING id, created_at, version`
+	if err := tx.QueryRowContext(ctx, query, role.Name, role.Description).Scan(&role.ID, &role.CreatedAt, &role.Version); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateRole:
+			return ErrDuplicateRole
+		default:
+			return err
+		}
+	}
+	if err := setRolePermissions(ctx, tx, role); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m RoleModel) Get(id int64) (*Role, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT roles.id, roles.created_at, roles.name, roles.description, roles.version,
+	array_remove(array_agg(roles_permissions.code ORDER BY roles_permissions.code), NULL)
+	FROM roles LEFT JOIN roles_permissions ON roles_permissions.role_id=roles.id
+	WHERE roles.id = $1 GROUP BY roles.id`
+	var role Role
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&role.ID, &role.CreatedAt, &role.Name, &role.Description, &role.Version, pq.Array((*[]string)(&role.Permissions)),
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &role, nil
+}
+
+func (m RoleModel) GetAll() ([]*Role, error) {
+	return m.query(`SELECT roles.id, roles.created_at, roles.name, roles.description, roles.version,
+	array_remove(array_agg(roles_permissions.code ORDER BY roles_permissions.code), NULL)
+	FROM roles LEFT JOIN roles_permissions ON roles_permissions.role_id=roles.id
+	GROUP BY roles.id ORDER BY roles.name`)
+}
+
+func (m RoleModel) GetAllForUser(userID int64) ([]*Role, error) {
+	return m.query(`SELECT roles.id, roles.created_at, roles.name, roles.description, roles.version,
+	array_remove(array_agg(roles_permissions.code ORDER BY roles_permissions.code), NULL)
+	FROM roles INNER JOIN users_roles ON users_roles.role_id=roles.id
+	LEFT JOIN roles_permissions ON roles_permissions.role_id=roles.id
+	WHERE users_roles.user_id = $1 GROUP BY roles.id ORDER BY roles.name`, userID)
+}
+
+func (m RoleModel) query(query string, args ...interface{}) ([]*Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.CreatedAt, &role.Name, &role.Description, &role.Version,
+			pq.Array((*[]string)(&role.Permissions))); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// Update saves a role and records entry in the audit log in the same
+// transaction. It returns ErrAdminLockout if the change would leave the actor
+// without users:admin.
+func (m RoleModel) Update(role *Role, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `UPDATE roles SET name = $1, description = $2, version = version + 1
+	WHERE id = $3 AND version = $4 RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, role.Name, role.Description, role.ID, role.Version).Scan(&role.Version); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateRole:
+			return ErrDuplicateRole
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM roles_permissions WHERE role_id = $1`, role.ID); err != nil {
+		return err
+	}
+	if err := setRolePermissions(ctx, tx, role); err != nil {
+		return err
+	}
+	if err := checkAdminLockout(ctx, tx, entry.ActorID, entry); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setRolePermissions(ctx context.Context, tx *sql.Tx, role *Role) error {
+	query := `INSERT INTO roles_permissions (role_id, code) SELECT $1, unnest($2::text[])`
+	_, err := tx.ExecContext(ctx, query, role.ID, pq.Array([]string(role.Permissions)))
+	return err
+}
+
+// Delete removes a role and records entry in the audit log in the same
+// transaction. It returns ErrAdminLockout if the actor would lose users:admin.
+func (m RoleModel) Delete(id int64, entry *AuditEntry) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	if err := checkAdminLockout(ctx, tx, entry.ActorID, entry); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m RoleModel) AddForUser(userID int64, names ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, addRolesQuery, userID, pq.Array(names))
+	return err
+}
+
+func (m RoleModel) RemoveForUser(userID int64, names ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, removeRolesQuery, userID, pq.Array(names))
+	return err
+}
+
+// AssignForUser gives roles to a user and records entry in the audit log in
+// the same transaction.
+func (m RoleModel) AssignForUser(userID int64, names []string, entry *AuditEntry) error {
+	return m.changeForUser(addRolesQuery, userID, names, entry)
+}
+
+// UnassignForUser takes roles from a user and records entry in the audit log
+// in the same transaction.
+func (m RoleModel) UnassignForUser(userID int64, names []string, entry *AuditEntry) error {
+	return m.changeForUser(removeRolesQuery, userID, names, entry)
+}
+
+func (m RoleModel) changeForUser(query string, userID int64, names []string, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(names)); err != nil {
+		return err
+	}
+	if err := checkAdminLockout(ctx, tx, userID, entry); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -6,22 +6,46 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
 )
 
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
+	ScopeEmailChange    = "email-change"
+	ScopeRefresh        = "refresh"
+	ScopeMFAPending     = "mfa-pending"
+	ScopeImpersonation  = "impersonation"
 )
 
 type Token struct {
 	Plaintext string    `json:"token"`
 	Hash      []byte    `json:"-"`
+	ID        int64     `json:"-"`
+	CreatedAt time.Time `json:"-"`
 	UserID    int64     `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	Email     string    `json:"-"`
+	IP        string    `json:"-"`
+	UserAgent string    `json:"-"`
+	Family    string    `json:"-"`
+	ActorID   *int64    `json:"-"`
+}
+
+type Session struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Expiry     time.Time  `json:"expiry"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Current    bool       `json:"current"`
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -35,11 +59,25 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 		return nil, err
 	}
 	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
-	hash := sha256.Sum256([]byte(token.Plaintext))
-	token.Hash = hash[:]
+	token.Hash = HashToken(token.Plaintext)
 	return token, nil
 }
 
+// NewTokenFamily returns a random identifier shared by an access token and
+// every refresh token descended from the same login.
+func NewTokenFamily() (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+func HashToken(tokenPlaintext string) []byte {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+	return hash[:]
+}
+
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 	v.Check(tokenPlaintext != "", "token", "must be provided")
 	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
@@ -47,8 +85,18 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 
 type TokensInterface interface {
 	New(userID int64, ttl time.Duration, scope string) (*Token, error)
+	NewForEmail(userID int64, ttl time.Duration, scope, email string) (*Token, error)
+	NewSession(userID int64, ttl time.Duration, scope, family, ip, userAgent string) (*Token, error)
+	NewImpersonation(userID, actorID int64, ttl time.Duration, entry *AuditEntry) (*Token, error)
 	Insert(token *Token) error
 	DeleteAllForUser(scope string, userID int64) error
+	DeleteByHash(scope string, hash []byte) error
+	GetSessionsForUser(userID int64, currentHash []byte) ([]*Session, error)
+	DeleteSessionForUser(userID, id int64) error
+	UpdateLastUsed(lastUsed map[string]time.Time) error
+	UseRefreshToken(tokenPlaintext string) (*Token, error)
+	DeleteFamily(scope, family string) error
+	GetSessionStart(hash []byte) (time.Time, error)
 }
 
 type TokenModel struct {
@@ -64,13 +112,71 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 
+func (m TokenModel) NewForEmail(userID int64, ttl time.Duration, scope, email string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	token.Email = email
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m TokenModel) NewSession(userID int64, ttl time.Duration, scope, family, ip, userAgent string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	token.Family = family
+	token.IP = ip
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	token.UserAgent = userAgent
+	err = m.Insert(token)
+	return token, err
+}
+
+// NewImpersonation issues a token that authenticates as userID on behalf of
+// the admin actorID, and records entry in the audit log in the same
+// transaction with the token's expiry added to its details.
+func (m TokenModel) NewImpersonation(userID, actorID int64, ttl time.Duration, entry *AuditEntry) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeImpersonation)
+	if err != nil {
+		return nil, err
+	}
+	token.ActorID = &actorID
+	if entry.Details == nil {
+		entry.Details = map[string]interface{}{}
+	}
+	entry.Details["expiry"] = token.Expiry
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := tx.QueryRowContext(ctx, insertTokenQuery, token.insertArgs()...).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	return token, tx.Commit()
+}
+
+const insertTokenQuery = `INSERT INTO tokens (hash, user_id, expiry, scope, email, ip, user_agent, family, actor_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`
+
+func (t *Token) insertArgs() []interface{} {
+	return []interface{}{t.Hash, t.UserID, t.Expiry, t.Scope, t.Email, t.IP, t.UserAgent, t.Family, t.ActorID}
+}
+
 func (m TokenModel) Insert(token *Token) error {
-	query := `INSERT INTO tokens (hash, user_id, expiry, scope) VALUES ($1, $2, $3, $4)`
-	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, args...)
-	return err
+	return m.DB.QueryRowContext(ctx, insertTokenQuery, token.insertArgs()...).Scan(&token.ID, &token.CreatedAt)
 }
 
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
@@ -80,3 +186,165 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+// DeleteByHash deletes the token with the given hash together with every
+// other token issued in the same family, so that logging out also revokes
+// the refresh token belonging to the session.
+func (m TokenModel) DeleteByHash(scope string, hash []byte) error {
+	query := `DELETE from tokens WHERE (scope = $1 and hash = $2)
+	OR family = (SELECT family FROM tokens WHERE scope = $1 and hash = $2 and family <> '')`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, scope, hash)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetSessionsForUser lists the user's logins, one per token family. A
+// session lasts as long as its access token or unused refresh token, and is
+// identified by the first token issued for it. Tokens issued before families
+// were introduced are listed individually.
+func (m TokenModel) GetSessionsForUser(userID int64, currentHash []byte) ([]*Session, error) {
+	query := `SELECT min(id), min(created_at), max(last_used_at),
+	max(expiry) FILTER (WHERE scope = $2 OR (scope = $4 AND used_at IS NULL)),
+	(array_agg(ip ORDER BY id DESC))[1], (array_agg(user_agent ORDER BY id DESC))[1], bool_or(hash = $3)
+	FROM tokens WHERE user_id = $1 AND scope IN ($2, $4)
+	GROUP BY CASE WHEN family = '' THEN id::text ELSE family END
+	HAVING max(expiry) FILTER (WHERE scope = $2 OR (scope = $4 AND used_at IS NULL)) > NOW()
+	ORDER BY min(created_at) DESC, min(id) DESC`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, currentHash, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.Expiry, &session.IP,
+			&session.UserAgent, &session.Current); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (m TokenModel) DeleteSessionForUser(userID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `DELETE FROM tokens WHERE user_id = $2 AND scope IN ($3, $4) AND (id = $1
+	OR family = (SELECT family FROM tokens WHERE id = $1 AND user_id = $2 AND scope IN ($3, $4) AND family <> ''))`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id, userID, ScopeAuthentication, ScopeRefresh)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateLastUsed records the last use time of many tokens in a single
+// statement. The map is keyed by the raw token hash.
+func (m TokenModel) UpdateLastUsed(lastUsed map[string]time.Time) error {
+	hashes := make([][]byte, 0, len(lastUsed))
+	times := make([]string, 0, len(lastUsed))
+	for hash, t := range lastUsed {
+		hashes = append(hashes, []byte(hash))
+		times = append(times, t.Format(time.RFC3339Nano))
+	}
+	query := `UPDATE tokens SET last_used_at = usage.last_used_at
+	FROM unnest($1::bytea[], $2::timestamptz[]) AS usage(hash, last_used_at)
+	WHERE tokens.hash = usage.hash`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(hashes), pq.Array(times))
+	return err
+}
+
+// UseRefreshToken marks a refresh token as used and returns it. Presenting a
+// refresh token that has already been used revokes its whole family and
+// returns ErrTokenReused.
+func (m TokenModel) UseRefreshToken(tokenPlaintext string) (*Token, error) {
+	query := `UPDATE tokens SET used_at = NOW()
+	WHERE hash = $1 AND scope = $2 AND expiry > NOW() AND used_at IS NULL
+	RETURNING id, created_at, user_id, expiry, family`
+	token := Token{Plaintext: tokenPlaintext, Hash: HashToken(tokenPlaintext), Scope: ScopeRefresh}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, token.Hash, ScopeRefresh).Scan(
+		&token.ID, &token.CreatedAt, &token.UserID, &token.Expiry, &token.Family,
+	)
+	if err == nil {
+		return &token, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	query = `SELECT family FROM tokens WHERE hash = $1 AND scope = $2 AND used_at IS NOT NULL`
+	if err := m.DB.QueryRowContext(ctx, query, token.Hash, ScopeRefresh).Scan(&token.Family); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if err := m.deleteFamily(ctx, token.Family); err != nil {
+		return nil, err
+	}
+	return nil, ErrTokenReused
+}
+
+// GetSessionStart returns when the session of the access token with the given
+// hash was logged in. Used refresh tokens are kept, so the oldest token in the
+// family dates from the login itself.
+func (m TokenModel) GetSessionStart(hash []byte) (time.Time, error) {
+	query := `SELECT min(created_at) FROM tokens
+	WHERE family = (SELECT family FROM tokens WHERE hash = $1 AND scope = $2 AND family <> '')`
+	var started sql.NullTime
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, hash, ScopeAuthentication).Scan(&started); err != nil {
+		return time.Time{}, err
+	}
+	if !started.Valid {
+		return time.Time{}, ErrRecordNotFound
+	}
+	return started.Time, nil
+}
+
+func (m TokenModel) DeleteFamily(scope, family string) error {
+	query := `DELETE FROM tokens WHERE scope = $1 AND family = $2 AND family <> ''`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, scope, family)
+	return err
+}
+
+func (m TokenModel) deleteFamily(ctx context.Context, family string) error {
+	query := `DELETE FROM tokens WHERE family = $1 AND family <> ''`
+	_, err := m.DB.ExecContext(ctx, query, family)
+	return err
+}
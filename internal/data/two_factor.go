@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+type TwoFactor struct {
+	UserID    int64
+	CreatedAt time.Time
+	Secret    string
+	Enabled   bool
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) == 6, "code", "must be 6 digits long")
+}
+
+// GenerateRecoveryCodes returns n random single-use codes formatted as two
+// groups of four characters, e.g. "ABCD-EFGH".
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		randomBytes := make([]byte, 5)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(randomBytes)
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+type TwoFactorInterface interface {
+	GetForUser(userID int64) (*TwoFactor, error)
+	SetSecret(userID int64, secret string) error
+	Enable(userID int64, recoveryCodes []string) error
+	Disable(userID int64) error
+	UseRecoveryCode(userID int64, code string) (bool, error)
+	UseStep(userID int64, step int64) (bool, error)
+}
+
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+func (m TwoFactorModel) GetForUser(userID int64) (*TwoFactor, error) {
+	query := `SELECT user_id, created_at, secret, enabled FROM users_totp WHERE user_id = $1`
+	var twoFactor TwoFactor
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&twoFactor.UserID, &twoFactor.CreatedAt, &twoFactor.Secret, &twoFactor.Enabled,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &twoFactor, nil
+}
+
+// SetSecret starts (or restarts) enrollment with a new secret. Two-factor
+// authentication stays disabled until Enable is called.
+func (m TwoFactorModel) SetSecret(userID int64, secret string) error {
+	query := `INSERT INTO users_totp (user_id, secret) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW()
+	WHERE users_totp.enabled = false`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (m TwoFactorModel) Enable(userID int64, recoveryCodes []string) error {
+	hashes := make([][]byte, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = HashToken(normalizeRecoveryCode(code))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `UPDATE users_totp SET enabled = true WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO recovery_codes (user_id, hash) SELECT $1, unnest($2::bytea[])`
+	if _, err := tx.ExecContext(ctx, query, userID, pq.Array(hashes)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m TwoFactorModel) Disable(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code, reporting whether it was valid.
+func (m TwoFactorModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	query := `DELETE FROM recovery_codes WHERE user_id = $1 AND hash = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, userID, HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// UseStep records the time step of an accepted TOTP code, reporting false if
+// a code for that step or a later one has already been used.
+func (m TwoFactorModel) UseStep(userID int64, step int64) (bool, error) {
+	query := `UPDATE users_totp SET last_step = $2 WHERE user_id = $1 AND (last_step IS NULL OR last_step < $2)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Sukrati192/greenlight/internal/validator"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -69,7 +71,9 @@ func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
 	ValidateEmail(v, user.Email)
-	ValidatePasswordPlainText(v, *user.Password.plaintext)
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlainText(v, *user.Password.plaintext)
+	}
 	if user.Password.hash == nil {
 		panic("missing password hash for user")
 	}
@@ -77,9 +81,16 @@ func ValidateUser(v *validator.Validator, user *User) {
 
 type UsersInterface interface {
 	Insert(user *User) error
+	Get(id int64) (*User, error)
+	GetAll(email string, filters Filters) ([]*User, Metadata, error)
 	GetByEmail(email string) (*User, error)
 	Update(user *User) error
+	Delete(id int64, entry *AuditEntry) error
+	Deactivate(user *User, entry *AuditEntry) error
+	Claim(user *User) error
 	GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+	GetForEmailChangeToken(tokenPlaintext string) (*User, string, error)
+	GetForImpersonationToken(tokenPlaintext string) (*User, int64, error)
 }
 
 type UserModel struct {
@@ -105,6 +116,27 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
+func (m UserModel) Get(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT id, created_at, name, email, password_hash, activated, version FROM users where id = $1`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID, &user.CreatedAt, &user.Name, &user.Email, &user.Password.hash, &user.Activated, &user.Version,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `SELECT id, created_at, name, email, password_hash, activated, version FROM users where email = $1`
 	var user User
@@ -135,6 +167,8 @@ func (m UserModel) Update(user *User) error {
 		switch {
 		case err.Error() == ErrPsqlDuplicateEmail:
 			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
 		default:
 			return err
 		}
@@ -142,6 +176,98 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
+// Deactivate marks the user as not activated, signs out every session and
+// impersonation of them, and records entry in the audit log, all in one
+// transaction.
+func (m UserModel) Deactivate(user *User, entry *AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `UPDATE users SET activated = false, version = version + 1 WHERE id = $1 AND version = $2 RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, user.ID, user.Version).Scan(&user.Version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	user.Activated = false
+	query = `DELETE FROM tokens WHERE user_id = $1 AND scope = ANY($2)`
+	scopes := []string{ScopeAuthentication, ScopeRefresh, ScopeImpersonation}
+	if _, err := tx.ExecContext(ctx, query, user.ID, pq.Array(scopes)); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Claim activates a user whose email address an identity provider has
+// verified. Whoever registered the account may not own the address, so the
+// caller must have set a new password, and every token of the user is deleted
+// in the same transaction.
+func (m UserModel) Claim(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `UPDATE users SET password_hash = $1, activated = true, version = version + 1
+	WHERE id = $2 AND version = $3 RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, user.Password.hash, user.ID, user.Version).Scan(&user.Version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	user.Activated = true
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1`, user.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a user and records entry in the audit log in the same
+// transaction. Their tokens, permissions, roles, API keys and linked
+// identities are removed with them by cascading foreign keys.
+func (m UserModel) Delete(id int64, entry *AuditEntry) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated,
@@ -169,3 +295,97 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	}
 	return &user, nil
 }
+
+func (m UserModel) GetForEmailChangeToken(tokenPlaintext string) (*User, string, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated,
+	users.version, tokens.email FROM users INNER JOIN tokens ON users.id=tokens.user_id
+	WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3`
+	args := []interface{}{tokenHash[:], ScopeEmailChange, time.Now()}
+	var user User
+	var newEmail string
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&newEmail,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, "", ErrRecordNotFound
+		default:
+			return nil, "", err
+		}
+	}
+	return &user, newEmail, nil
+}
+
+// GetForImpersonationToken returns the impersonated user along with the ID of
+// the admin acting as them.
+func (m UserModel) GetForImpersonationToken(tokenPlaintext string) (*User, int64, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated,
+	users.version, tokens.actor_id FROM users INNER JOIN tokens ON users.id=tokens.user_id
+	WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3`
+	args := []interface{}{tokenHash[:], ScopeImpersonation, time.Now()}
+	var user User
+	var actorID int64
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&actorID,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, 0, ErrRecordNotFound
+		default:
+			return nil, 0, err
+		}
+	}
+	return &user, actorID, nil
+}
+
+func (m UserModel) GetAll(email string, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, created_at, name, email, activated, version FROM users
+	WHERE (email ILIKE '%%' || $1 || '%%' OR $1 = '')
+	ORDER BY %s %s, id ASC LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, email, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanUsers(rows, filters)
+}
+
+func scanUsers(rows *sql.Rows, filters Filters) ([]*User, Metadata, error) {
+	defer rows.Close()
+	totalRecords := 0
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&totalRecords, &user.ID, &user.CreatedAt, &user.Name, &user.Email, &user.Activated,
+			&user.Version); err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return users, metadata, nil
+}
@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/validator"
+)
+
+var ErrDuplicateWatchlistItem = errors.New("duplicate watchlist item")
+
+const ErrPsqlDuplicateWatchlistItem = `pq: duplicate key value violates unique constraint "watchlist_items_pkey"`
+
+type WatchlistItem struct {
+	UserID    int64      `json:"-"`
+	MovieID   int64      `json:"movie_id"`
+	Title     string     `json:"title"`
+	Year      int32      `json:"year,omitempty"`
+	AddedAt   time.Time  `json:"added_at"`
+	Watched   bool       `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at,omitempty"`
+}
+
+func ValidateWatchlistItem(v *validator.Validator, item *WatchlistItem) {
+	if item.Watched {
+		v.Check(item.WatchedAt != nil, "watched_at", "must be provided")
+		v.Check(item.WatchedAt == nil || !item.WatchedAt.After(time.Now()), "watched_at", "must not be in the future")
+	} else {
+		v.Check(item.WatchedAt == nil, "watched_at", "must not be provided for an unwatched movie")
+	}
+}
+
+type WatchlistInterface interface {
+	Insert(item *WatchlistItem) error
+	Get(userID, movieID int64) (*WatchlistItem, error)
+	Update(item *WatchlistItem) error
+	Delete(userID, movieID int64) error
+	GetAll(userID int64, watched *bool, filters Filters) ([]*WatchlistItem, Metadata, error)
+	GetAllForUser(userID int64) ([]*WatchlistItem, error)
+}
+
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+func (m WatchlistModel) Insert(item *WatchlistItem) error {
+	query := `INSERT INTO watchlist_items (user_id, movie_id, watched, watched_at) VALUES ($1, $2, $3, $4)
+	RETURNING added_at, (SELECT title FROM movies WHERE id = $2), (SELECT year FROM movies WHERE id = $2)`
+	args := []interface{}{item.UserID, item.MovieID, item.Watched, item.WatchedAt}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&item.AddedAt, &item.Title, &item.Year); err != nil {
+		switch {
+		case err.Error() == ErrPsqlDuplicateWatchlistItem:
+			return ErrDuplicateWatchlistItem
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WatchlistModel) Get(userID, movieID int64) (*WatchlistItem, error) {
+	query := `SELECT watchlist_items.user_id, watchlist_items.movie_id, movies.title, movies.year,
+	watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at
+	FROM watchlist_items INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1 AND watchlist_items.movie_id = $2 AND movies.deleted_at IS NULL`
+	var item WatchlistItem
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(
+		&item.UserID, &item.MovieID, &item.Title, &item.Year, &item.AddedAt, &item.Watched, &item.WatchedAt,
+	); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &item, nil
+}
+
+func (m WatchlistModel) Update(item *WatchlistItem) error {
+	query := `UPDATE watchlist_items SET watched = $1, watched_at = $2 WHERE user_id = $3 AND movie_id = $4`
+	args := []interface{}{item.Watched, item.WatchedAt, item.UserID, item.MovieID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m WatchlistModel) Delete(userID, movieID int64) error {
+	query := `DELETE FROM watchlist_items WHERE user_id = $1 AND movie_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns a page of the user's watchlist, optionally only the watched
+// or unwatched movies.
+func (m WatchlistModel) GetAll(userID int64, watched *bool, filters Filters) ([]*WatchlistItem, Metadata, error) {
+	query := fmt.Sprintf(`SELECT count(*) OVER(), watchlist_items.user_id, watchlist_items.movie_id, movies.title, movies.year,
+	watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at
+	FROM watchlist_items INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1 AND movies.deleted_at IS NULL AND (watchlist_items.watched = $2 OR $2 IS NULL)
+	ORDER BY %s %s, movie_id ASC LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID, watched, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	items := []*WatchlistItem{}
+	for rows.Next() {
+		var item WatchlistItem
+		if err := rows.Scan(&totalRecords, &item.UserID, &item.MovieID, &item.Title, &item.Year,
+			&item.AddedAt, &item.Watched, &item.WatchedAt); err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+func (m WatchlistModel) GetAllForUser(userID int64) ([]*WatchlistItem, error) {
+	query := `SELECT watchlist_items.user_id, watchlist_items.movie_id, movies.title, movies.year,
+	watchlist_items.added_at, watchlist_items.watched, watchlist_items.watched_at
+	FROM watchlist_items INNER JOIN movies ON movies.id = watchlist_items.movie_id
+	WHERE watchlist_items.user_id = $1 ORDER BY watchlist_items.added_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*WatchlistItem{}
+	for rows.Next() {
+		var item WatchlistItem
+		if err := rows.Scan(&item.UserID, &item.MovieID, &item.Title, &item.Year,
+			&item.AddedAt, &item.Watched, &item.WatchedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type Claims struct {
+	Subject     int64    `json:"sub"`
+	Activated   bool     `json:"act"`
+	Permissions []string `json:"perms"`
+	IssuedAt    int64    `json:"iat"`
+	Expiry      int64    `json:"exp"`
+}
+
+// Signer issues and verifies HS256 JSON Web Tokens. Tokens are always signed
+// with the current key, but any configured key is accepted on verification so
+// that keys can be rotated without invalidating tokens already in circulation.
+type Signer struct {
+	keys  map[string][]byte
+	keyID string
+}
+
+func New(keys map[string][]byte, keyID string) (*Signer, error) {
+	if _, ok := keys[keyID]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return &Signer{keys: keys, keyID: keyID}, nil
+}
+
+func (s *Signer) Sign(claims Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: s.keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encode(h) + "." + encode(payload)
+	return signingInput + "." + encode(sign(s.keys[s.keyID], signingInput)), nil
+}
+
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if h.Algorithm != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	key, ok := s.keys[h.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.Expiry {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func sign(key []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(s string, target interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
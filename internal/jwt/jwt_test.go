@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	now := time.Now()
+	signer, err := New(map[string][]byte{"k1": []byte("secret-one")}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.Sign(Claims{Subject: 42, Activated: true, Permissions: []string{"movies:read"}, Expiry: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := signer.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.Subject != 42 || !claims.Activated || len(claims.Permissions) != 1 {
+		t.Errorf("Verify() = %+v", claims)
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Now()
+	oldSigner, _ := New(map[string][]byte{"k1": []byte("secret-one")}, "k1")
+	rotated, _ := New(map[string][]byte{"k1": []byte("secret-one"), "k2": []byte("secret-two")}, "k2")
+	other, _ := New(map[string][]byte{"k1": []byte("another-secret")}, "k1")
+
+	valid, _ := oldSigner.Sign(Claims{Subject: 1, Expiry: now.Add(time.Minute).Unix()})
+	expired, _ := oldSigner.Sign(Claims{Subject: 1, Expiry: now.Add(-time.Minute).Unix()})
+	forged, _ := other.Sign(Claims{Subject: 1, Expiry: now.Add(time.Minute).Unix()})
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		wantErr error
+	}{
+		{"valid", oldSigner, valid, nil},
+		{"rotated key still verifies", rotated, valid, nil},
+		{"expired", oldSigner, expired, ErrExpiredToken},
+		{"wrong secret", oldSigner, forged, ErrInvalidToken},
+		{"tampered payload", oldSigner, parts[0] + "." + encode([]byte(`{"sub":2,"exp":9999999999}`)) + "." + parts[2], ErrInvalidToken},
+		{"malformed", oldSigner, "abc", ErrInvalidToken},
+		{"unknown key", other, func() string { s, _ := rotated.Sign(Claims{Expiry: now.Add(time.Minute).Unix()}); return s }(), ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.signer.Verify(tt.token, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// stubProvider is a minimal identity provider. It issues one authorization
+// code per login and only redeems it with the matching PKCE verifier.
+type stubProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.server.URL,
+			"authorization_endpoint": s.server.URL + "/authorize",
+			"token_endpoint":         s.server.URL + "/token",
+			"jwks_uri":               s.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("code") != "code" ||
+			Challenge(r.FormValue("code_verifier")) != s.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claims := map[string]interface{}{
+			"iss":            s.server.URL,
+			"sub":            "user-1",
+			"aud":            "client",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          s.nonce,
+			"email":          "alice@example.com",
+			"email_verified": true,
+		}
+		for k, v := range s.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s.sign(t, claims)})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *stubProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// login follows the redirect a browser would, recording the PKCE challenge
+// and nonce the provider received, then exchanges the code.
+func (s *stubProvider) login(t *testing.T, p *Provider, verifier string) (*IDToken, error) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "client" || q.Get("state") != "state" {
+		t.Fatalf("AuthCodeURL() = %s", authURL)
+	}
+	s.challenge, s.nonce = q.Get("code_challenge"), q.Get("nonce")
+	return p.Exchange(context.Background(), "code", verifier, "nonce")
+}
+
+func TestExchange(t *testing.T) {
+	s := newStubProvider(t)
+	p := New(Config{Issuer: s.server.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "http://localhost/callback"}, nil)
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.login(t, p, verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if token.Subject != "user-1" || token.Email != "alice@example.com" || !token.EmailVerified {
+		t.Errorf("Exchange() = %+v", token)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   error
+	}{
+		{"wrong audience", map[string]interface{}{"aud": []string{"other"}}, ErrInvalidIDToken},
+		{"wrong issuer", map[string]interface{}{"iss": "https://evil.example.com"}, ErrInvalidIDToken},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}, ErrInvalidIDToken},
+		{"replayed nonce", map[string]interface{}{"nonce": "other"}, ErrNonceMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStubProvider(t)
+			s.claims = tt.claims
+			p := New(Config{Issuer: s.server.URL, ClientID: "client", ClientSecret: "secret"}, nil)
+			if _, err := s.login(t, p, "verifier"); !errors.Is(err, tt.want) {
+				t.Errorf("Exchange() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	s := newStubProvider(t)
+	p := New(Config{Issuer: s.server.URL, ClientID: "client", ClientSecret: "secret"}, nil)
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err != nil {
+		t.Fatal(err)
+	}
+	s.challenge = Challenge("verifier")
+	if _, err := p.Exchange(context.Background(), "code", "another-verifier", "nonce"); !errors.Is(err, ErrExchangeFailed) {
+		t.Errorf("Exchange() error = %v, want %v", err, ErrExchangeFailed)
+	}
+}
+
+func TestExchangeForgedSignature(t *testing.T) {
+	s := newStubProvider(t)
+	p := New(Config{Issuer: s.server.URL, ClientID: "client"}, nil)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.key, other = other, s.key
+	forged := s.sign(t, map[string]interface{}{"iss": s.server.URL, "sub": "x", "aud": "client", "exp": time.Now().Add(time.Minute).Unix()})
+	s.key = other
+	if _, err := p.verify(context.Background(), forged, "", time.Now()); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("verify() error = %v, want %v", err, ErrInvalidIDToken)
+	}
+}
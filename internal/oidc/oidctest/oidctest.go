@@ -0,0 +1,121 @@
+// Package oidctest provides a stub OpenID Connect identity provider for tests
+// of code that signs users in through package oidc.
+package oidctest
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Sukrati192/greenlight/internal/oidc"
+)
+
+// Provider is a minimal identity provider. It issues the authorization code
+// "code" for the client "client" with secret "secret", and signs ID tokens
+// for subject "user-1" with a verified email address. Claims overrides or
+// adds claims of the ID token.
+type Provider struct {
+	Server *httptest.Server
+	Claims map[string]interface{}
+
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func NewProvider(t *testing.T) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Provider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.Server.URL,
+			"authorization_endpoint": s.Server.URL + "/authorize",
+			"token_endpoint":         s.Server.URL + "/token",
+			"jwks_uri":               s.Server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("code") != "code" ||
+			oidc.Challenge(r.FormValue("code_verifier")) != s.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claims := map[string]interface{}{
+			"iss":            s.Server.URL,
+			"sub":            "user-1",
+			"aud":            "client",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          s.nonce,
+			"email":          "alice@example.com",
+			"email_verified": true,
+		}
+		for k, v := range s.Claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s.sign(t, claims)})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Server.Close)
+	return s
+}
+
+// Config returns the configuration of a client of the provider.
+func (s *Provider) Config() oidc.Config {
+	return oidc.Config{Issuer: s.Server.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "http://localhost/callback"}
+}
+
+// Login follows the redirect a browser would, recording the PKCE challenge
+// and nonce the provider received, then exchanges the code.
+func (s *Provider) Login(t *testing.T, p *oidc.Provider) (*oidc.IDToken, error) {
+	t.Helper()
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.challenge, s.nonce = u.Query().Get("code_challenge"), u.Query().Get("nonce")
+	return p.Exchange(context.Background(), "code", verifier, "nonce")
+}
+
+func (s *Provider) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// Test vectors for HMAC-SHA1 from RFC 6238 Appendix B.
+func TestGenerateRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		if got := generate(key, uint64(tt.unix/period), 8); got != tt.want {
+			t.Errorf("generate(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		want bool
+	}{
+		{"current period", code, now, true},
+		{"previous period", code, now.Add(period * time.Second), true},
+		{"outside skew", code, now.Add(3 * period * time.Second), false},
+		{"wrong length", code[:5], now, false},
+		{"wrong code", "000000", now, code == "000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, got := Validate(secret, tt.code, tt.at)
+			if got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+			if got && step != now.Unix()/period {
+				t.Errorf("Validate() step = %d, want %d", step, now.Unix()/period)
+			}
+		})
+	}
+}
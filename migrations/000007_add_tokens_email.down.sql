@@ -0,0 +1 @@
+ALTER TABLE tokens DROP COLUMN IF EXISTS email;
\ No newline at end of file
@@ -0,0 +1,12 @@
+INSERT INTO permissions (code) VALUES ('users:admin');
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    actor_id bigint NOT NULL,
+    target_user_id bigint,
+    action text NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_target_user_id_idx ON audit_log (target_user_id);
\ No newline at end of file
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text UNIQUE NOT NULL,
+    description text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions (
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    code text NOT NULL,
+    PRIMARY KEY (role_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS users_roles (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('viewer', 'Can browse the movie catalogue'),
+    ('editor', 'Can browse and edit the movie catalogue'),
+    ('admin', 'Full access, including user administration');
+
+INSERT INTO roles_permissions (role_id, code)
+    SELECT id, 'movies:read' FROM roles WHERE name = 'viewer'
+    UNION ALL SELECT id, 'movies:*' FROM roles WHERE name = 'editor'
+    UNION ALL SELECT id, '*' FROM roles WHERE name = 'admin';
\ No newline at end of file
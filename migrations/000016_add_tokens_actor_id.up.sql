@@ -0,0 +1 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS actor_id bigint REFERENCES users ON DELETE CASCADE;
\ No newline at end of file
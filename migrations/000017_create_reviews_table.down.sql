@@ -0,0 +1,4 @@
+DELETE FROM roles WHERE name = 'moderator';
+DELETE FROM roles_permissions WHERE code LIKE 'reviews:%';
+DELETE FROM permissions WHERE code IN ('reviews:write', 'reviews:moderate');
+DROP TABLE IF EXISTS reviews;
\ No newline at end of file
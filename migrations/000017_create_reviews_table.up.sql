@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    rating integer NOT NULL,
+    body text NOT NULL,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT reviews_rating_check CHECK (rating BETWEEN 1 AND 10),
+    CONSTRAINT reviews_movie_id_user_id_key UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+INSERT INTO permissions (code) VALUES ('reviews:write'), ('reviews:moderate');
+
+INSERT INTO users_permissions (user_id, permission_id)
+    SELECT users_permissions.user_id, (SELECT id FROM permissions WHERE code = 'reviews:write')
+    FROM users_permissions INNER JOIN permissions ON permissions.id = users_permissions.permission_id
+    WHERE permissions.code = 'movies:read'
+    ON CONFLICT DO NOTHING;
+
+INSERT INTO roles (name, description) VALUES ('moderator', 'Can browse the movie catalogue and moderate reviews')
+    ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, code)
+    SELECT id, 'reviews:write' FROM roles WHERE name IN ('viewer', 'editor')
+    UNION ALL SELECT id, 'movies:read' FROM roles WHERE name = 'moderator'
+    UNION ALL SELECT id, 'reviews:*' FROM roles WHERE name = 'moderator'
+    ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS watchlist_items (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    watched boolean NOT NULL DEFAULT false,
+    watched_at timestamp(0) with time zone,
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_items_movie_id_idx ON watchlist_items (movie_id);
\ No newline at end of file
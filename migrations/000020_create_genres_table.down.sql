@@ -0,0 +1,3 @@
+-- Movies keep their canonical slugs; the original spellings are only
+-- recoverable from the aliases, which are dropped here.
+DROP TABLE IF EXISTS genres;
\ No newline at end of file
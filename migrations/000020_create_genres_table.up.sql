@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text UNIQUE NOT NULL,
+    name text NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+-- Build the vocabulary from the genres already in use. Spellings that
+-- normalise to the same slug become one genre, and every original spelling
+-- is kept as an alias so nothing is lost. Genres without any ASCII letters
+-- or digits get a slug from a hash of their spelling, matching Slugify.
+WITH originals AS (
+    SELECT DISTINCT genre,
+        COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(genre), '[^a-z0-9]+', '-', 'g')), ''), 'genre-' || left(md5(genre), 8)) AS slug
+    FROM movies, unnest(genres) AS genre
+)
+INSERT INTO genres (slug, name, aliases)
+    SELECT slug, min(genre), array_agg(genre ORDER BY genre)
+    FROM originals GROUP BY slug
+    ON CONFLICT DO NOTHING;
+
+WITH normalized AS (
+    SELECT id, ARRAY(
+        SELECT slug FROM (
+            SELECT COALESCE(NULLIF(trim(both '-' from regexp_replace(lower(genre), '[^a-z0-9]+', '-', 'g')), ''), 'genre-' || left(md5(genre), 8)) AS slug,
+                min(position) AS position
+            FROM unnest(movies.genres) WITH ORDINALITY AS g(genre, position)
+            GROUP BY 1
+        ) slugs ORDER BY position
+    ) AS genres
+    FROM movies
+)
+UPDATE movies SET genres = normalized.genres, version = movies.version + 1
+    FROM normalized
+    WHERE normalized.id = movies.id AND normalized.genres <> movies.genres;
\ No newline at end of file
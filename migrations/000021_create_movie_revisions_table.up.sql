@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL,
+    action text NOT NULL,
+    actor_id bigint NOT NULL,
+    snapshot jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_idx ON movie_revisions (movie_id, id);
\ No newline at end of file
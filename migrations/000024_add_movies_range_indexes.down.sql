@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_year_idx;
+DROP INDEX IF EXISTS movies_runtime_idx;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS movies_year_idx ON movies (year) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS movies_runtime_idx ON movies (runtime) WHERE deleted_at IS NULL;
\ No newline at end of file
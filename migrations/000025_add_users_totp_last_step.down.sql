@@ -0,0 +1 @@
+ALTER TABLE users_totp DROP COLUMN IF EXISTS last_step;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users_totp ADD COLUMN IF NOT EXISTS last_step bigint;
\ No newline at end of file